@@ -0,0 +1,686 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Batch settlement: reads orders from JSON Lines or CSV, one at a time,
+// writes a summary line per order, a reject line per bad order and a
+// settlement report totalled per currency and per seller. Items name their
+// seller or fall back to the order's.
+//
+//	calculate-order -in orders.jsonl -out summaries.jsonl -rejects rejects.jsonl -report report.json
+//
+// A JSON Lines order looks like
+//
+//	{"id": "o-1", "seller": "s-1", "currency": "EUR", "date": "2024-05-02",
+//	 "vat_rate": "0.19", "processing_fee": {"percent": "0.029", "fixed": "0.25"},
+//	 "platform_fee_percent": "0.10",
+//	 "seller_platform_fees": {"s-2": "0.15"},
+//	 "items": [{"sku": "TSHIRT", "unit_price": "50.00", "qty": 2},
+//	           {"sku": "MUG", "unit_price": "20.00", "qty": 1, "seller": "s-2"}]}
+//
+// A CSV file has one row per item under the header in csvHeader; rows of
+// the same order_id must be consecutive, and each row's platform_fee_percent
+// is charged to that row's seller.
+func main() {
+	var (
+		inPath      = flag.String("in", "", "orders file, .jsonl or .csv (default stdin as JSON Lines)")
+		format      = flag.String("format", "", "input format: jsonl or csv (default from -in extension)")
+		outPath     = flag.String("out", "", "per-order summaries, JSON Lines (default stdout)")
+		rejectsPath = flag.String("rejects", "rejects.jsonl", "rejected orders with the reason, JSON Lines")
+		reportPath  = flag.String("report", "report.json", "settlement report, JSON")
+		ratesPath   = flag.String("rates", "", "exchange rates file, .csv or .json")
+		settle      = flag.String("settle", "RON", "settlement currency")
+		strict      = flag.Bool("strict", false, "reject orders with invalid fields instead of skipping and clamping")
+	)
+	flag.Parse()
+
+	cfg := batchConfig{SettlementCurrency: *settle}
+	if *strict {
+		cfg.Validation = Strict
+	}
+	if *ratesPath != "" {
+		rates, err := LoadRateTable(*ratesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Rates = rates
+	}
+
+	in := io.Reader(os.Stdin)
+	if *inPath != "" {
+		f, err := os.Open(*inPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	if *format == "" && strings.EqualFold(filepath.Ext(*inPath), ".csv") {
+		*format = "csv"
+	}
+	var orders orderReader
+	switch *format {
+	case "", "jsonl":
+		orders = newJSONLReader(in)
+	case "csv":
+		orders = newCSVReader(in)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "" {
+		f := mustCreate(*outPath)
+		defer f.Close()
+		out = f
+	}
+	rejects := mustCreate(*rejectsPath)
+	defer rejects.Close()
+
+	report, err := runBatch(cfg, orders, out, rejects)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f := mustCreate(*reportPath)
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d orders settled, %d rejected", report.Orders, report.Rejected)
+}
+
+func mustCreate(path string) *os.File {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f
+}
+
+// --- data models ---
+
+type batchConfig struct {
+	SettlementCurrency string
+	Rates              ExchangeRateProvider
+	Validation         Validation
+}
+
+// batchOrder is one order read from the input. Err is set when the
+// record could not be turned into an Order; Raw is what gets rejected.
+type batchOrder struct {
+	Line  int
+	ID    string
+	Order Order
+	Raw   any
+	Err   error
+}
+
+type orderReader interface {
+	// Read returns the next order, or io.EOF when the input is exhausted.
+	Read() (batchOrder, error)
+}
+
+type summaryRecord struct {
+	ID                 string `json:"id"`
+	Currency           string `json:"currency"`
+	Subtotal           string `json:"subtotal"`
+	Discount           string `json:"discount"`
+	VAT                string `json:"vat"`
+	TotalCollected     string `json:"total_collected"`
+	ProcessingFee      string `json:"processing_fee"`
+	PlatformFee        string `json:"platform_fee"`
+	SellerPayout       string `json:"seller_payout"`
+	SettlementCurrency string `json:"settlement_currency"`
+	ExchangeRate       string `json:"exchange_rate"`
+	RateDate           string `json:"rate_date,omitempty"`
+	SellerPayoutSettle string `json:"seller_payout_settled"`
+	PlatformRevSettle  string `json:"platform_revenue_settled"`
+
+	Sellers []sellerRecord `json:"sellers"`
+}
+
+type sellerRecord struct {
+	Seller             string `json:"seller"`
+	PlatformFeePercent string `json:"platform_fee_percent"`
+	Subtotal           string `json:"subtotal"`
+	VAT                string `json:"vat"`
+	ProcessingFee      string `json:"processing_fee"`
+	PlatformFee        string `json:"platform_fee"`
+	SellerPayout       string `json:"seller_payout"`
+	SellerPayoutSettle string `json:"seller_payout_settled"`
+}
+
+type rejectRecord struct {
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+	Record any    `json:"record"`
+}
+
+// Totals are decimal strings in the report, kept as Money while summing.
+type reportTotals struct {
+	Orders          int    `json:"orders"`
+	Subtotal        string `json:"subtotal"`
+	Discount        string `json:"discount"`
+	VAT             string `json:"vat"`
+	TotalCollected  string `json:"total_collected"`
+	ProcessingFee   string `json:"processing_fee"`
+	PlatformFee     string `json:"platform_fee"`
+	SellerPayout    string `json:"seller_payout"`
+	SettledPayout   string `json:"seller_payout_settled"`
+	SettledRevenue  string `json:"platform_revenue_settled"`
+	SettlementCcy   string `json:"settlement_currency"`
+	currency        string
+	sum             Summary
+	settledPayout   Money
+	settledRevenue  Money
+	settlementCcyOK bool
+}
+
+type settlementReport struct {
+	Orders     int                                 `json:"orders"`
+	Rejected   int                                 `json:"rejected"`
+	ByCurrency map[string]*reportTotals            `json:"by_currency"`
+	BySeller   map[string]map[string]*reportTotals `json:"by_seller"` // seller → currency → totals
+}
+
+// --- main logic ---
+
+func runBatch(cfg batchConfig, orders orderReader, out, rejects io.Writer) (settlementReport, error) {
+	report := settlementReport{
+		ByCurrency: map[string]*reportTotals{},
+		BySeller:   map[string]map[string]*reportTotals{},
+	}
+	outW, rejectW := bufio.NewWriter(out), bufio.NewWriter(rejects)
+	outEnc, rejectEnc := json.NewEncoder(outW), json.NewEncoder(rejectW)
+
+	for {
+		b, err := orders.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+
+		var s Summary
+		if b.Err == nil {
+			b.Order.SettlementCurrency = cmp.Or(b.Order.SettlementCurrency, cfg.SettlementCurrency)
+			if b.Order.Rates == nil {
+				b.Order.Rates = cfg.Rates
+			}
+			b.Order.Validation = max(b.Order.Validation, cfg.Validation)
+			s, b.Err = CalculateSummary(b.Order)
+		}
+		if b.Err == nil && s.Currency == "" {
+			b.Err = ErrNoBillableItems // Lenient mode settles nothing
+		}
+		if b.Err != nil {
+			report.Rejected++
+			reason := strings.ReplaceAll(b.Err.Error(), "\n", "; ")
+			if err := rejectEnc.Encode(rejectRecord{Line: b.Line, ID: b.ID, Reason: reason, Record: b.Raw}); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		report.Orders++
+		if err := outEnc.Encode(newSummaryRecord(b, s)); err != nil {
+			return report, err
+		}
+		report.add(s)
+	}
+
+	for _, t := range report.ByCurrency {
+		t.format()
+	}
+	for _, byCur := range report.BySeller {
+		for _, t := range byCur {
+			t.format()
+		}
+	}
+	if err := outW.Flush(); err != nil {
+		return report, err
+	}
+	return report, rejectW.Flush()
+}
+
+func newSummaryRecord(b batchOrder, s Summary) summaryRecord {
+	exp, settleExp := minorUnits(s.Currency), minorUnits(s.Settlement.Currency)
+	rec := summaryRecord{
+		ID:                 b.ID,
+		Currency:           s.Currency,
+		Subtotal:           s.Subtotal.Format(exp),
+		Discount:           s.Discount.Format(exp),
+		VAT:                s.VAT.Format(exp),
+		TotalCollected:     s.TotalCollected.Format(exp),
+		ProcessingFee:      s.ProcessingFee.Format(exp),
+		PlatformFee:        s.PlatformFee.Format(exp),
+		SellerPayout:       s.SellerPayout.Format(exp),
+		SettlementCurrency: s.Settlement.Currency,
+		ExchangeRate:       s.Settlement.Rate.String(),
+		SellerPayoutSettle: s.Settlement.SellerPayout.Format(settleExp),
+		PlatformRevSettle:  s.Settlement.PlatformRevenue.Format(settleExp),
+	}
+	if !s.Settlement.RateDate.IsZero() {
+		rec.RateDate = s.Settlement.RateDate.Format(dateLayout)
+	}
+	for _, sh := range s.Sellers {
+		rec.Sellers = append(rec.Sellers, sellerRecord{
+			Seller:             sh.SellerID,
+			PlatformFeePercent: sh.PlatformFeePercent.String(),
+			Subtotal:           sh.Subtotal.Format(exp),
+			VAT:                sh.VAT.Format(exp),
+			ProcessingFee:      sh.ProcessingFee.Format(exp),
+			PlatformFee:        sh.PlatformFee.Format(exp),
+			SellerPayout:       sh.SellerPayout.Format(exp),
+			SellerPayoutSettle: sh.SettledPayout.Format(settleExp),
+		})
+	}
+	return rec
+}
+
+func (r *settlementReport) add(s Summary) {
+	totals := func(m map[string]*reportTotals, s Summary) {
+		t, ok := m[s.Currency]
+		if !ok {
+			t = &reportTotals{currency: s.Currency}
+			m[s.Currency] = t
+		}
+		t.add(s)
+	}
+	totals(r.ByCurrency, s)
+	for _, sh := range s.Sellers {
+		if r.BySeller[sh.SellerID] == nil {
+			r.BySeller[sh.SellerID] = map[string]*reportTotals{}
+		}
+		totals(r.BySeller[sh.SellerID], Summary{
+			Currency:       s.Currency,
+			Subtotal:       sh.Subtotal,
+			Discount:       sh.Discount,
+			VAT:            sh.VAT,
+			TotalCollected: sh.TotalCollected,
+			ProcessingFee:  sh.ProcessingFee,
+			PlatformFee:    sh.PlatformFee,
+			SellerPayout:   sh.SellerPayout,
+			Settlement: Settlement{
+				Currency:        s.Settlement.Currency,
+				SellerPayout:    sh.SettledPayout,
+				PlatformRevenue: sh.SettledRevenue,
+			},
+		})
+	}
+}
+
+func (t *reportTotals) add(s Summary) {
+	t.Orders++
+	t.sum.Subtotal += s.Subtotal
+	t.sum.Discount += s.Discount
+	t.sum.VAT += s.VAT
+	t.sum.TotalCollected += s.TotalCollected
+	t.sum.ProcessingFee += s.ProcessingFee
+	t.sum.PlatformFee += s.PlatformFee
+	t.sum.SellerPayout += s.SellerPayout
+
+	// settled amounts only add up when every order settled in the same currency
+	switch {
+	case t.Orders == 1:
+		t.SettlementCcy, t.settlementCcyOK = s.Settlement.Currency, true
+	case t.SettlementCcy != s.Settlement.Currency:
+		t.settlementCcyOK = false
+	}
+	t.settledPayout += s.Settlement.SellerPayout
+	t.settledRevenue += s.Settlement.PlatformRevenue
+}
+
+func (t *reportTotals) format() {
+	exp := minorUnits(t.currency)
+	t.Subtotal = t.sum.Subtotal.Format(exp)
+	t.Discount = t.sum.Discount.Format(exp)
+	t.VAT = t.sum.VAT.Format(exp)
+	t.TotalCollected = t.sum.TotalCollected.Format(exp)
+	t.ProcessingFee = t.sum.ProcessingFee.Format(exp)
+	t.PlatformFee = t.sum.PlatformFee.Format(exp)
+	t.SellerPayout = t.sum.SellerPayout.Format(exp)
+	if t.settlementCcyOK {
+		settleExp := minorUnits(t.SettlementCcy)
+		t.SettledPayout = t.settledPayout.Format(settleExp)
+		t.SettledRevenue = t.settledRevenue.Format(settleExp)
+	} else {
+		t.SettlementCcy = "mixed"
+	}
+}
+
+// --- JSON Lines input ---
+
+type decimal string
+
+// UnmarshalJSON accepts both 12.34 and "12.34", keeping the exact digits.
+func (d *decimal) UnmarshalJSON(b []byte) error {
+	var s string
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	} else {
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return err
+		}
+		s = n.String()
+	}
+	*d = decimal(s)
+	return nil
+}
+
+type orderRecord struct {
+	ID                 string  `json:"id"`
+	Seller             string  `json:"seller"`
+	Currency           string  `json:"currency"`
+	Date               string  `json:"date"`
+	SettlementCurrency string  `json:"settlement_currency"`
+	VATRate            decimal `json:"vat_rate"`
+	ProcessingFee      struct {
+		Percent decimal `json:"percent"`
+		Fixed   decimal `json:"fixed"`
+	} `json:"processing_fee"`
+	PlatformFeePercent decimal            `json:"platform_fee_percent"`
+	SellerPlatformFees map[string]decimal `json:"seller_platform_fees"`
+	TaxRates           map[string]decimal `json:"tax_rates"`
+	PricesIncludeTax   bool               `json:"prices_include_tax"`
+	Items              []orderItemRecord  `json:"items"`
+	Rounding           string             `json:"rounding"`
+}
+
+type orderItemRecord struct {
+	SKU         string  `json:"sku"`
+	UnitPrice   decimal `json:"unit_price"`
+	Qty         int     `json:"qty"`
+	TaxCategory string  `json:"tax_category"`
+	Seller      string  `json:"seller"` // defaults to the order's seller
+}
+
+type jsonlReader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &jsonlReader{sc: sc}
+}
+
+func (r *jsonlReader) Read() (batchOrder, error) {
+	for r.sc.Scan() {
+		r.line++
+		raw := bytes.TrimSpace(r.sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		b := batchOrder{Line: r.line, Raw: string(raw)}
+		if json.Valid(raw) {
+			b.Raw = json.RawMessage(slices.Clone(raw))
+		}
+		var rec orderRecord
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rec); err != nil {
+			b.Err = err
+			return b, nil
+		}
+		b.ID = rec.ID
+		b.Order, b.Err = rec.order()
+		return b, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return batchOrder{}, err
+	}
+	return batchOrder{}, io.EOF
+}
+
+func (rec orderRecord) order() (Order, error) {
+	mode := HalfUp
+	switch rec.Rounding {
+	case "", "half-up":
+	case "half-even":
+		mode = HalfEven
+	default:
+		return Order{}, fmt.Errorf("rounding: unknown mode %q", rec.Rounding)
+	}
+	cur, err := LookupCurrency(rec.Currency)
+	if err != nil {
+		return Order{}, fmt.Errorf("currency: %w", err)
+	}
+
+	var errs []error
+	money := func(field string, d decimal) Money {
+		if d == "" {
+			return 0
+		}
+		m, err := ParseMoney(string(d), cur.Exponent, mode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+		return m
+	}
+	rate := func(field string, d decimal) Rate {
+		if d == "" {
+			return 0
+		}
+		r, err := ParseRate(string(d))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+		return r
+	}
+
+	o := Order{
+		Currency:           cur.Code,
+		Rounding:           mode,
+		SettlementCurrency: rec.SettlementCurrency,
+		VATRate:            rate("vat_rate", rec.VATRate),
+		ProcessingFee: Fee{
+			Percent: rate("processing_fee.percent", rec.ProcessingFee.Percent),
+			Fixed:   money("processing_fee.fixed", rec.ProcessingFee.Fixed),
+		},
+		PlatformFeePercent: rate("platform_fee_percent", rec.PlatformFeePercent),
+		PricesIncludeTax:   rec.PricesIncludeTax,
+	}
+	if rec.Date != "" {
+		o.Date, err = time.Parse(dateLayout, rec.Date)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("date: %w", err))
+		}
+	}
+	for c, d := range rec.TaxRates {
+		if o.TaxRates == nil {
+			o.TaxRates = map[TaxCategory]Rate{}
+		}
+		o.TaxRates[TaxCategory(c)] = rate("tax_rates."+c, d)
+	}
+	for id, d := range rec.SellerPlatformFees {
+		if o.SellerPlatformFees == nil {
+			o.SellerPlatformFees = map[string]Rate{}
+		}
+		o.SellerPlatformFees[id] = rate("seller_platform_fees."+id, d)
+	}
+	for i, it := range rec.Items {
+		unit, total, err := ParseLinePrice(cmp.Or(string(it.UnitPrice), "0"), it.Qty, cur.Exponent, mode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("items[%d].unit_price: %w", i, err))
+		}
+		o.Items = append(o.Items, LineItem{
+			SKU:         it.SKU,
+			UnitPrice:   unit,
+			Qty:         it.Qty,
+			TaxCategory: TaxCategory(it.TaxCategory),
+			SellerID:    cmp.Or(it.Seller, rec.Seller),
+			Total:       total,
+		})
+	}
+	return o, errors.Join(errs...)
+}
+
+// --- CSV input ---
+
+var csvHeader = []string{
+	"order_id", "seller", "currency", "date", "vat_rate",
+	"processing_fee_percent", "processing_fee_fixed", "platform_fee_percent",
+	"sku", "unit_price", "qty", "tax_category",
+}
+
+type csvRow struct {
+	fields []string
+	line   int
+	err    error // a malformed row, rejected on its own
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	col     map[string]int
+	width   int
+	pending *csvRow // first row of the next order
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	return &csvReader{r: cr}
+}
+
+func (r *csvReader) Read() (batchOrder, error) {
+	if r.col == nil {
+		header, err := r.r.Read()
+		if err != nil {
+			return batchOrder{}, err
+		}
+		r.col, r.width = map[string]int{}, len(header)
+		for i, name := range header {
+			r.col[strings.TrimSpace(name)] = i
+		}
+		for _, name := range []string{"order_id", "currency", "sku", "unit_price", "qty"} {
+			if _, ok := r.col[name]; !ok {
+				return batchOrder{}, fmt.Errorf("csv: missing column %q", name)
+			}
+		}
+	}
+
+	first, err := r.next()
+	if err != nil {
+		return batchOrder{}, err
+	}
+	if first.err != nil {
+		return batchOrder{Line: first.line, Err: first.err}, nil
+	}
+
+	id := r.field(first.fields, "order_id")
+	rows := [][]string{first.fields}
+	for {
+		row, err := r.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return batchOrder{}, err
+		}
+		if row.err != nil || r.field(row.fields, "order_id") != id {
+			r.pending = &row
+			break
+		}
+		rows = append(rows, row.fields)
+	}
+
+	b := batchOrder{Line: first.line, ID: id, Raw: rows}
+	b.Order, b.Err = r.order(rows)
+	return b, nil
+}
+
+func (r *csvReader) next() (csvRow, error) {
+	if r.pending != nil {
+		row := *r.pending
+		r.pending = nil
+		return row, nil
+	}
+	fields, err := r.r.Read()
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return csvRow{line: perr.StartLine, err: err}, nil
+	}
+	if err != nil {
+		return csvRow{}, err
+	}
+	line, _ := r.r.FieldPos(0)
+	return csvRow{fields: fields, line: line}, nil
+}
+
+func (r *csvReader) field(row []string, name string) string {
+	i, ok := r.col[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+func (r *csvReader) order(rows [][]string) (Order, error) {
+	first := rows[0]
+	rec := orderRecord{
+		Currency:           r.field(first, "currency"),
+		Date:               r.field(first, "date"),
+		VATRate:            decimal(r.field(first, "vat_rate")),
+		PlatformFeePercent: decimal(r.field(first, "platform_fee_percent")),
+	}
+	rec.ProcessingFee.Percent = decimal(r.field(first, "processing_fee_percent"))
+	rec.ProcessingFee.Fixed = decimal(r.field(first, "processing_fee_fixed"))
+
+	for i, row := range rows {
+		if len(row) != r.width {
+			return Order{}, fmt.Errorf("row %d: expected %d fields, got %d", i+1, r.width, len(row))
+		}
+		qty, err := strconv.Atoi(r.field(row, "qty"))
+		if err != nil {
+			return Order{}, fmt.Errorf("row %d qty: %w", i+1, err)
+		}
+		rec.Items = append(rec.Items, orderItemRecord{
+			SKU:         r.field(row, "sku"),
+			UnitPrice:   decimal(r.field(row, "unit_price")),
+			Qty:         qty,
+			TaxCategory: r.field(row, "tax_category"),
+			Seller:      r.field(row, "seller"),
+		})
+
+		seller, fee := r.field(row, "seller"), decimal(r.field(row, "platform_fee_percent"))
+		if fee == "" {
+			continue
+		}
+		if prev, ok := rec.SellerPlatformFees[seller]; ok && prev != fee {
+			return Order{}, fmt.Errorf("row %d: seller %q platform fee %s, earlier rows say %s", i+1, seller, fee, prev)
+		}
+		if rec.SellerPlatformFees == nil {
+			rec.SellerPlatformFees = map[string]decimal{}
+		}
+		rec.SellerPlatformFees[seller] = fee
+	}
+	return rec.order()
+}
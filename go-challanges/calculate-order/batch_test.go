@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const batchJSONL = `{"id": "o-1", "seller": "s-1", "currency": "RON", "vat_rate": "0.19", "processing_fee": {"percent": "0.02", "fixed": "1.00"}, "platform_fee_percent": "0.10", "items": [{"sku": "A", "unit_price": "100.00", "qty": 1}]}
+{"id": "o-2", "seller": "s-1", "currency": "RON", "vat_rate": 0.19, "processing_fee": {"percent": 0.02, "fixed": 1}, "platform_fee_percent": 0.10, "items": [{"sku": "B", "unit_price": 50, "qty": 2}]}
+
+{"id": "o-3", "seller": "s-2", "currency": "EUR", "date": "2024-05-02", "vat_rate": "0.19", "items": [{"sku": "C", "unit_price": "10.00", "qty": 1}]}
+{"id": "o-4", "seller": "s-2", "currency": "XXX", "items": [{"sku": "C", "unit_price": "10.00", "qty": 1}]}
+{"id": "o-5", "seller": "s-2", "currency": "RON", "items": [{"sku": "C", "unit_price": "10.00", "qty": 0}]}
+{"id": "o-6", "seller": "s-2", "currency": "RON", "items": [{"sku": "C", "unit_price": "ten", "qty": 1}]}
+{"id": "o-7", "seller": "s-2", "currency": "RON", "colour": "red"}
+not json
+`
+
+const batchCSV = `order_id,seller,currency,date,vat_rate,processing_fee_percent,processing_fee_fixed,platform_fee_percent,sku,unit_price,qty,tax_category
+o-1,s-1,RON,,0.19,0.02,1.00,0.10,A,100.00,1,
+o-2,s-1,RON,,0.19,0.02,1.00,0.10,B,25.00,2,
+o-2,s-1,RON,,0.19,0.02,1.00,0.10,B2,50.00,1,
+o-3,s-2,EUR,2024-05-02,0.19,,,,C,10.00,1,
+o-4,s-2,RON,,0.19,,,,C,10.00,two,
+o-5,s-2,RON,,0.19,,,,"C,10.00,1,
+`
+
+func runTestBatch(t *testing.T, cfg batchConfig, orders orderReader) (settlementReport, []summaryRecord, []rejectRecord) {
+	t.Helper()
+	rates, err := LoadRateTable("testdata/rates.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Rates = rates
+	cfg.SettlementCurrency = "RON"
+
+	var out, rejects bytes.Buffer
+	report, err := runBatch(cfg, orders, &out, &rejects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report, decodeLines[summaryRecord](t, &out), decodeLines[rejectRecord](t, &rejects)
+}
+
+func decodeLines[T any](t *testing.T, buf *bytes.Buffer) []T {
+	t.Helper()
+	var out []T
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var v T
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			t.Fatalf("%s: %v", sc.Text(), err)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func TestRunBatch_JSONL(t *testing.T) {
+	report, summaries, rejects := runTestBatch(t, batchConfig{}, newJSONLReader(strings.NewReader(batchJSONL)))
+
+	if report.Orders != 3 || report.Rejected != 5 {
+		t.Fatalf("got %d orders and %d rejected, want 3 and 5", report.Orders, report.Rejected)
+	}
+	if len(summaries) != 3 || summaries[0].ID != "o-1" || summaries[0].SellerPayout != "86.62" {
+		t.Errorf("unexpected summaries: %+v", summaries)
+	}
+	if s := summaries[2]; s.SellerPayout != "10.00" || s.SellerPayoutSettle != "49.73" || s.ExchangeRate != "4.9731" || s.RateDate != "2024-05-02" {
+		t.Errorf("o-3: unexpected settlement %+v", s)
+	}
+
+	wantLines := []int{5, 6, 7, 8, 9}
+	for i, r := range rejects {
+		if r.Line != wantLines[i] || r.Reason == "" || r.Record == nil {
+			t.Errorf("reject %d: %+v", i, r)
+		}
+	}
+
+	ron := report.ByCurrency["RON"]
+	if ron.Orders != 2 || ron.Subtotal != "200.00" || ron.VAT != "38.00" || ron.ProcessingFee != "6.76" || ron.SellerPayout != "173.24" || ron.SettledPayout != "173.24" {
+		t.Errorf("RON totals: %+v", ron)
+	}
+	if s2 := report.BySeller["s-2"]["EUR"]; s2.Orders != 1 || s2.SellerPayout != "10.00" || s2.SettledPayout != "49.73" || s2.SettlementCcy != "RON" {
+		t.Errorf("s-2 EUR totals: %+v", s2)
+	}
+	if s1 := report.BySeller["s-1"]["RON"]; s1.Orders != 2 || s1.PlatformFee != "20.00" {
+		t.Errorf("s-1 RON totals: %+v", s1)
+	}
+}
+
+func TestRunBatch_CSV(t *testing.T) {
+	report, summaries, rejects := runTestBatch(t, batchConfig{}, newCSVReader(strings.NewReader(batchCSV)))
+
+	if report.Orders != 3 || report.Rejected != 2 {
+		t.Fatalf("got %d orders and %d rejected, want 3 and 2", report.Orders, report.Rejected)
+	}
+	if summaries[1].ID != "o-2" || summaries[1].Subtotal != "100.00" || summaries[1].SellerPayout != "86.62" {
+		t.Errorf("o-2: %+v", summaries[1])
+	}
+	if rejects[0].ID != "o-4" || rejects[0].Line != 6 || !strings.Contains(rejects[0].Reason, "qty") {
+		t.Errorf("o-4: %+v", rejects[0])
+	}
+	if rejects[1].Line != 7 {
+		t.Errorf("malformed row: %+v", rejects[1])
+	}
+	if ron := report.ByCurrency["RON"]; ron.SellerPayout != "173.24" {
+		t.Errorf("RON totals: %+v", ron)
+	}
+}
+
+func TestRunBatch_StrictRejectsInvalidItems(t *testing.T) {
+	in := `{"id": "o-1", "currency": "RON", "vat_rate": "0.19", "items": [{"sku": "A", "unit_price": "10.00", "qty": 1}, {"sku": "B", "unit_price": "5.00", "qty": -1}]}`
+
+	report, _, _ := runTestBatch(t, batchConfig{}, newJSONLReader(strings.NewReader(in)))
+	if report.Orders != 1 {
+		t.Errorf("lenient: expected the bad item to be skipped, got %+v", report)
+	}
+
+	report, _, rejects := runTestBatch(t, batchConfig{Validation: Strict}, newJSONLReader(strings.NewReader(in)))
+	if report.Rejected != 1 || !strings.Contains(rejects[0].Reason, "Qty") {
+		t.Errorf("strict: expected a Qty rejection, got %+v %+v", report, rejects)
+	}
+}
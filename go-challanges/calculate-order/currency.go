@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Currency is an ISO 4217 currency: Exponent is the number of minor-unit
+// decimals it is settled in (2 for EUR, 0 for JPY, 3 for KWD).
+type Currency struct {
+	Code     string
+	Exponent int
+	Symbol   string
+}
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// --- registry ---
+
+var (
+	currenciesMu sync.RWMutex
+	currencies   = map[string]Currency{}
+)
+
+func init() {
+	for _, c := range []Currency{
+		// 2 decimals
+		{"RON", 2, "lei"}, {"EUR", 2, "€"}, {"USD", 2, "$"}, {"GBP", 2, "£"},
+		{"CHF", 2, "CHF"}, {"PLN", 2, "zł"}, {"CZK", 2, "Kč"}, {"HUF", 2, "Ft"},
+		{"BGN", 2, "лв"}, {"MDL", 2, "L"}, {"RSD", 2, "дин"}, {"UAH", 2, "₴"},
+		{"SEK", 2, "kr"}, {"NOK", 2, "kr"}, {"DKK", 2, "kr"}, {"TRY", 2, "₺"},
+		{"CAD", 2, "$"}, {"AUD", 2, "$"}, {"NZD", 2, "$"}, {"MXN", 2, "$"},
+		{"BRL", 2, "R$"}, {"CNY", 2, "¥"}, {"INR", 2, "₹"}, {"ZAR", 2, "R"},
+		// 0 decimals
+		{"JPY", 0, "¥"}, {"KRW", 0, "₩"}, {"CLP", 0, "$"}, {"ISK", 0, "kr"},
+		{"VND", 0, "₫"}, {"PYG", 0, "₲"}, {"UGX", 0, "USh"}, {"XOF", 0, "CFA"},
+		{"XAF", 0, "FCFA"}, {"XPF", 0, "₣"},
+		// 3 decimals
+		{"KWD", 3, "KD"}, {"BHD", 3, "BD"}, {"OMR", 3, "﷼"}, {"JOD", 3, "JD"},
+		{"TND", 3, "DT"}, {"LYD", 3, "LD"}, {"IQD", 3, "ع.د"},
+		// 4 decimals (units of account)
+		{"CLF", 4, "UF"}, {"UYW", 4, "UP"},
+	} {
+		if err := RegisterCurrency(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterCurrency adds or replaces a currency in the registry.
+func RegisterCurrency(c Currency) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	currenciesMu.Lock()
+	defer currenciesMu.Unlock()
+	currencies[c.Code] = c
+	return nil
+}
+
+// LookupCurrency returns the registered currency for an ISO 4217 code.
+func LookupCurrency(code string) (Currency, error) {
+	currenciesMu.RLock()
+	c, ok := currencies[code]
+	currenciesMu.RUnlock()
+	if !ok {
+		return Currency{}, fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+func (c Currency) Validate() error {
+	if len(c.Code) != 3 {
+		return fmt.Errorf("currency code %q: must be 3 letters", c.Code)
+	}
+	for _, r := range c.Code {
+		if r < 'A' || r > 'Z' {
+			return fmt.Errorf("currency code %q: must be upper-case A-Z", c.Code)
+		}
+	}
+	if c.Exponent < 0 || c.Exponent > 4 {
+		return fmt.Errorf("currency %s: exponent %d out of range 0-4", c.Code, c.Exponent)
+	}
+	return nil
+}
+
+// --- helpers ---
+
+// minorUnits is the number of decimals a currency is settled in. Unknown
+// codes fall back to 2, the ISO 4217 default; CalculateSummary rejects
+// them before this matters.
+func minorUnits(code string) int {
+	c, err := LookupCurrency(code)
+	if err != nil {
+		return 2
+	}
+	return c.Exponent
+}
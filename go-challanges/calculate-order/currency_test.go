@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLookupCurrency(t *testing.T) {
+	cases := map[string]int{"RON": 2, "EUR": 2, "JPY": 0, "CLP": 0, "ISK": 0, "KWD": 3, "BHD": 3}
+	for code, want := range cases {
+		c, err := LookupCurrency(code)
+		if err != nil {
+			t.Errorf("%s: %v", code, err)
+			continue
+		}
+		if c.Exponent != want {
+			t.Errorf("%s: expected exponent %d, got %d", code, want, c.Exponent)
+		}
+	}
+
+	if _, err := LookupCurrency("XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("expected ErrUnknownCurrency, got %v", err)
+	}
+}
+
+func TestRegisterCurrency_Validation(t *testing.T) {
+	for _, c := range []Currency{{Code: "eu", Exponent: 2}, {Code: "eur", Exponent: 2}, {Code: "ABC", Exponent: 7}} {
+		if err := RegisterCurrency(c); err == nil {
+			t.Errorf("expected %+v to be rejected", c)
+		}
+	}
+}
+
+func TestCalculateOrderSummary_ThreeDecimalCurrency(t *testing.T) {
+	in := OrderInput{
+		Currency:      "KWD",
+		ExchangeRates: map[string]float64{"KWD": 14.9},
+		Items: []Item{
+			{SKU: "LAMP", UnitPrice: 12.345, Qty: 2},
+		},
+		VATRate:            0.05,
+		ProcessingFee:      ProcessingFee{Percent: 0.02, Fixed: 0.1},
+		PlatformFeePercent: 0.1,
+	}
+
+	got := CalculateOrderSummary(in)
+	want := OrderSummary{
+		Currency:           "KWD",
+		Subtotal:           24.690,
+		VAT:                1.235, // 1.2345 → half-up
+		TotalCollected:     25.925,
+		ProcessingFee:      0.619, // 0.5185 + 0.1
+		PlatformFee:        2.469,
+		SellerPayout:       21.602,
+		PlatformRevenue:    2.469,
+		SellerPayoutRON:    321.87,
+		PlatformRevenueRON: 36.79,
+	}
+	assertExact(t, got, want)
+}
+
+func TestCalculateOrderSummary_ZeroDecimalCurrency(t *testing.T) {
+	in := OrderInput{
+		Currency:      "CLP",
+		ExchangeRates: map[string]float64{"CLP": 0.0049},
+		Items: []Item{
+			{SKU: "EMPANADA", UnitPrice: 1250, Qty: 3},
+		},
+		VATRate:            0.19,
+		ProcessingFee:      ProcessingFee{Percent: 0.029, Fixed: 100},
+		PlatformFeePercent: 0.1,
+	}
+
+	got := CalculateOrderSummary(in)
+	want := OrderSummary{
+		Currency:           "CLP",
+		Subtotal:           3750,
+		VAT:                713, // 712.5 → half-up
+		TotalCollected:     4463,
+		ProcessingFee:      229, // 129.427 + 100
+		PlatformFee:        375,
+		SellerPayout:       3146,
+		PlatformRevenue:    375,
+		SellerPayoutRON:    15.42,
+		PlatformRevenueRON: 1.84,
+	}
+	assertExact(t, got, want)
+}
+
+func TestCalculateSummary_UnknownCurrency(t *testing.T) {
+	o := Order{
+		Currency: "ABC",
+		Items:    []LineItem{{SKU: "X", UnitPrice: 100, Qty: 1}},
+	}
+	if _, err := CalculateSummary(o); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("expected ErrUnknownCurrency, got %v", err)
+	}
+	if got := CalculateOrderSummary(OrderInput{Currency: "ABC", Items: []Item{{SKU: "X", UnitPrice: 1, Qty: 1}}}); !reflect.DeepEqual(got, OrderSummary{}) {
+		t.Fatalf("expected empty summary, got %+v", got)
+	}
+}
+
+// assertExact compares the legacy fields of two summaries without the
+// 0.01 tolerance assertEqual allows, which would hide errors in
+// 3-decimal currencies.
+func assertExact(t *testing.T, got, want OrderSummary) {
+	t.Helper()
+	legacy := func(s OrderSummary) [9]float64 {
+		return [9]float64{s.Subtotal, s.VAT, s.TotalCollected, s.ProcessingFee, s.PlatformFee,
+			s.SellerPayout, s.PlatformRevenue, s.SellerPayoutRON, s.PlatformRevenueRON}
+	}
+	if got.Currency != want.Currency || legacy(got) != legacy(want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got, want)
+	}
+}
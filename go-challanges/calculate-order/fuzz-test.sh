@@ -0,0 +1,3 @@
+for target in FuzzCalculateOrderSummary FuzzClamp FuzzRoundCurrency FuzzDefaultIf; do
+	go test -run XXX -fuzz="^$target\$" -fuzztime=30s || exit 1
+done
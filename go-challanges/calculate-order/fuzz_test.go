@@ -0,0 +1,242 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// Invariants every summary must satisfy, whatever the input. They hold
+// exactly on Money; the float fields are the same numbers divided by
+// 10^exponent.
+func checkInvariants(t *testing.T, o Order, s Summary) {
+	t.Helper()
+	if s.Currency == "" {
+		return // nothing billable
+	}
+
+	if s.Subtotal+s.VAT != s.TotalCollected {
+		t.Errorf("subtotal %d + VAT %d != total %d", s.Subtotal, s.VAT, s.TotalCollected)
+	}
+	if s.SellerPayout+s.ProcessingFee+s.PlatformFee+s.VAT != s.TotalCollected {
+		t.Errorf("payout %d + fees %d + %d + VAT %d != total %d", s.SellerPayout, s.ProcessingFee, s.PlatformFee, s.VAT, s.TotalCollected)
+	}
+	if s.PlatformRevenue != s.PlatformFee {
+		t.Errorf("platform revenue %d != platform fee %d", s.PlatformRevenue, s.PlatformFee)
+	}
+
+	var net, vat Money
+	for _, b := range s.VATBreakdown {
+		net += b.Net
+		vat += b.VAT
+	}
+	if net != s.Subtotal || vat != s.VAT {
+		t.Errorf("VAT breakdown sums to %d/%d, want %d/%d", net, vat, s.Subtotal, s.VAT)
+	}
+
+	var payout, settled Money
+	for _, sh := range s.Sellers {
+		payout += sh.SellerPayout
+		settled += sh.SettledPayout
+		if sh.SellerPayout < 0 && sh.SettledPayout > 0 || sh.SellerPayout > 0 && sh.SettledPayout < 0 {
+			t.Errorf("seller %q payout %d settled as %d", sh.SellerID, sh.SellerPayout, sh.SettledPayout)
+		}
+	}
+	if payout != s.SellerPayout || settled != s.Settlement.SellerPayout {
+		t.Errorf("seller shares sum to %d/%d, want %d/%d", payout, settled, s.SellerPayout, s.Settlement.SellerPayout)
+	}
+
+	_, gross := o.billableLines()
+	var saved Money
+	for _, p := range s.Promotions {
+		saved += p.Saved
+	}
+	if saved != s.Discount || s.Discount < 0 || s.Discount > gross {
+		t.Errorf("discount %d (promotions %d) out of 0..%d", s.Discount, saved, gross)
+	}
+	if o.MaxDiscount > 0 && s.Discount > o.MaxDiscount {
+		t.Errorf("discount %d over MaxDiscount %d", s.Discount, o.MaxDiscount)
+	}
+	if s.VAT < 0 || s.ProcessingFee < 0 || s.PlatformFee < 0 || s.PlatformFee > s.Subtotal {
+		t.Errorf("fee out of range: %+v", s)
+	}
+}
+
+func FuzzCalculateOrderSummary(f *testing.F) {
+	f.Add(100.0, 2, 0.19, 0.029, 0.30, 0.10, false, uint8(0))
+	f.Add(1.005, 3, 0.09, 0.0, 0.0, 0.0, true, uint8(1))
+	f.Add(0.333, 7, 0.21, 0.015, 0.125, 0.2, false, uint8(2))
+	f.Add(-5.0, 0, -1.0, -0.5, -1.0, 2.0, false, uint8(0))
+
+	currencies := []string{"RON", "JPY", "KWD"}
+	f.Fuzz(func(t *testing.T, price float64, qty int, vat, pfPercent, pfFixed, platform float64, inclusive bool, cur uint8) {
+		for _, v := range []float64{price, vat, pfPercent, pfFixed, platform} {
+			if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) > 1e9 {
+				t.Skip()
+			}
+		}
+		if qty > 1_000_000 {
+			t.Skip()
+		}
+
+		in := OrderInput{
+			Items:              []Item{{SKU: "X", UnitPrice: price, Qty: qty}},
+			VATRate:            vat,
+			ProcessingFee:      ProcessingFee{Percent: pfPercent, Fixed: pfFixed},
+			PlatformFeePercent: platform,
+			Currency:           currencies[int(cur)%len(currencies)],
+			PricesIncludeTax:   inclusive,
+			ExchangeRates:      map[string]float64{"JPY": 0.031, "KWD": 14.9},
+		}
+		o, err := in.exact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := CalculateSummary(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkInvariants(t, o, s)
+
+		got := CalculateOrderSummary(in)
+		exp := minorUnits(in.Currency)
+		if got.TotalCollected != s.TotalCollected.Float64(exp) || got.SellerPayout != s.SellerPayout.Float64(exp) {
+			t.Errorf("float summary %+v differs from exact %+v", got, s)
+		}
+
+		// one more unit never lowers what anyone is owed
+		if s.Currency != "" {
+			o.Items[0].Qty++
+			more, err := CalculateSummary(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if more.Subtotal < s.Subtotal || more.VAT < s.VAT || more.TotalCollected < s.TotalCollected ||
+				more.ProcessingFee < s.ProcessingFee || more.PlatformFee < s.PlatformFee {
+				t.Errorf("qty %d → %d lowered an amount:\n%+v\n%+v", qty, qty+1, s, more)
+			}
+		}
+	})
+}
+
+func FuzzClamp(f *testing.F) {
+	f.Add(int64(5), int64(0), int64(10))
+	f.Add(int64(-1), int64(0), int64(10))
+	f.Add(int64(11), int64(0), int64(10))
+
+	f.Fuzz(func(t *testing.T, v, lo, hi int64) {
+		if lo > hi {
+			t.Skip()
+		}
+		got := clamp(v, lo, hi)
+		if got < lo || got > hi {
+			t.Errorf("clamp(%d, %d, %d) = %d out of range", v, lo, hi, got)
+		}
+		if v >= lo && v <= hi && got != v {
+			t.Errorf("clamp(%d, %d, %d) = %d changed an in-range value", v, lo, hi, got)
+		}
+		if clamp(got, lo, hi) != got {
+			t.Errorf("clamp is not idempotent for %d", v)
+		}
+		if got := clampMin(v, lo); got < lo || (v >= lo && got != v) {
+			t.Errorf("clampMin(%d, %d) = %d", v, lo, got)
+		}
+	})
+}
+
+func FuzzRoundCurrency(f *testing.F) {
+	f.Add(1.005, "RON")
+	f.Add(2.675, "EUR")
+	f.Add(-0.5, "JPY")
+	f.Add(1.0005, "KWD")
+	f.Add(123.456, "XXX")
+
+	f.Fuzz(func(t *testing.T, v float64, currency string) {
+		if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) > 1e12 {
+			t.Skip()
+		}
+		exp := minorUnits(currency)
+		got := roundCurrency(v, currency)
+		if again := roundCurrency(got, currency); again != got {
+			t.Errorf("roundCurrency(%v, %q) = %v, then %v", v, currency, got, again)
+		}
+		if half := math.Pow10(-exp) / 2; math.Abs(got-v) > half*(1+1e-9) {
+			t.Errorf("roundCurrency(%v, %q) = %v moved more than half a unit", v, currency, got)
+		}
+		if roundCurrency(-v, currency) != -got {
+			t.Errorf("roundCurrency(%v, %q) is not symmetric", v, currency)
+		}
+	})
+}
+
+func FuzzDefaultIf(f *testing.F) {
+	f.Add(0.19, 0.19)
+	f.Add(-1.0, 0.19)
+	f.Add(0.0, 0.19)
+
+	f.Fuzz(func(t *testing.T, v, def float64) {
+		notPositive := func(x float64) bool { return x <= 0 }
+		got := defaultIf(v, def, notPositive)
+		switch {
+		case notPositive(v) && got != def:
+			t.Errorf("defaultIf(%v, %v) = %v, want the default", v, def, got)
+		case !notPositive(v) && got != v && !math.IsNaN(v):
+			t.Errorf("defaultIf(%v, %v) = %v, want the value", v, def, got)
+		}
+	})
+}
+
+// TestCalculateSummary_Invariants checks the invariants on random
+// multi-item orders with promotions, sellers and mixed VAT, which the
+// single-item fuzz target cannot reach.
+func TestCalculateSummary_Invariants(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	pick := func(vs ...string) string { return vs[rng.IntN(len(vs))] }
+	rate := func(max int) Rate { return Rate(rng.IntN(max+1)) * RateScale / 1000 }
+
+	for n := range 2000 {
+		o := Order{
+			Currency:           pick("RON", "EUR", "JPY", "KWD"),
+			VATRate:            rate(300),
+			TaxRates:           map[TaxCategory]Rate{TaxReduced: rate(150)},
+			ProcessingFee:      Fee{Percent: rate(50), Fixed: Money(rng.IntN(100))},
+			PlatformFeePercent: rate(300),
+			SellerPlatformFees: map[string]Rate{"s-2": rate(300)},
+			PricesIncludeTax:   rng.IntN(2) == 0,
+			TaxRounding:        TaxRounding(rng.IntN(2)),
+			Rounding:           RoundingMode(rng.IntN(2)),
+			MaxDiscount:        Money(rng.IntN(3) * 1000),
+			SettlementCurrency: "RON",
+			Rates: StaticRates{Rates: map[CurrencyPair]Rate{
+				{"EUR", "RON"}: RateFromFloat(4.9731), {"JPY", "RON"}: RateFromFloat(0.0298), {"KWD", "RON"}: RateFromFloat(14.93),
+			}},
+		}
+		for range 1 + rng.IntN(6) {
+			o.Items = append(o.Items, LineItem{
+				SKU:         pick("A", "B", "C", ""),
+				UnitPrice:   Money(rng.IntN(100_000) - 100),
+				Qty:         rng.IntN(12) - 1,
+				TaxCategory: TaxCategory(pick("", "reduced", "zero")),
+				SellerID:    pick("s-1", "s-2", "s-3"),
+			})
+		}
+		if rng.IntN(2) == 0 {
+			o.Promotions = append(o.Promotions, Promotion{Code: "P", Kind: PercentOff, Percent: rate(500)})
+		}
+		if rng.IntN(2) == 0 {
+			o.Promotions = append(o.Promotions, Promotion{Code: "A", Kind: AmountOff, Amount: Money(rng.IntN(5000))})
+		}
+		if rng.IntN(2) == 0 {
+			o.Promotions = append(o.Promotions, Promotion{Code: "B", Kind: BuyXGetY, SKU: "A", Buy: 2, Get: 1})
+		}
+
+		s, err := CalculateSummary(o)
+		if err != nil {
+			t.Fatalf("order %d: %v", n, err)
+		}
+		checkInvariants(t, o, s)
+		if t.Failed() {
+			t.Fatalf("order %d: %+v", n, o)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type CurrencyPair struct {
+	From string
+	To   string
+}
+
+func (p CurrencyPair) String() string {
+	return p.From + "/" + p.To
+}
+
+// ExchangeRate is the price of one unit of From in To, as published on Date.
+type ExchangeRate struct {
+	Pair CurrencyPair
+	Rate Rate
+	Date time.Time
+}
+
+// ExchangeRateProvider looks up the rate to use for an order placed on date.
+type ExchangeRateProvider interface {
+	Rate(from, to string, date time.Time) (ExchangeRate, error)
+}
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+const dateLayout = "2006-01-02"
+
+// --- static rates ---
+
+// StaticRates is an ExchangeRateProvider with one fixed rate per pair,
+// whatever the date. Every rate it returns is dated AsOf.
+type StaticRates struct {
+	AsOf  time.Time
+	Rates map[CurrencyPair]Rate
+}
+
+func (s StaticRates) Rate(from, to string, date time.Time) (ExchangeRate, error) {
+	pair := CurrencyPair{from, to}
+	r, ok := s.Rates[pair]
+	if !ok || r <= 0 {
+		return ExchangeRate{}, fmt.Errorf("%w for %s", ErrRateNotFound, pair)
+	}
+	return ExchangeRate{Pair: pair, Rate: r, Date: s.AsOf}, nil
+}
+
+// --- dated rate table ---
+
+// RateTable is an ExchangeRateProvider over a history of published rates.
+// For an order date it returns the latest rate published on or before
+// that day; a zero date returns the latest rate overall.
+type RateTable struct {
+	rates map[CurrencyPair][]ExchangeRate // sorted by Date
+}
+
+func NewRateTable(rates ...ExchangeRate) *RateTable {
+	t := &RateTable{rates: map[CurrencyPair][]ExchangeRate{}}
+	for _, r := range rates {
+		t.rates[r.Pair] = append(t.rates[r.Pair], r)
+	}
+	for _, history := range t.rates {
+		slices.SortStableFunc(history, func(a, b ExchangeRate) int { return a.Date.Compare(b.Date) })
+	}
+	return t
+}
+
+func (t *RateTable) Rate(from, to string, date time.Time) (ExchangeRate, error) {
+	pair := CurrencyPair{from, to}
+	history := t.rates[pair]
+	if date.IsZero() && len(history) > 0 {
+		return history[len(history)-1], nil
+	}
+
+	day := truncateDay(date)
+	// history[:i] were published on or before day
+	i := sort.Search(len(history), func(i int) bool { return truncateDay(history[i].Date).After(day) })
+	if i == 0 {
+		return ExchangeRate{}, fmt.Errorf("%w for %s on %s", ErrRateNotFound, pair, date.Format(dateLayout))
+	}
+	return history[i-1], nil
+}
+
+// --- file-backed rates ---
+
+// LoadRateTable reads a .csv or .json rate file, see ReadRatesCSV and
+// ReadRatesJSON for the formats.
+func LoadRateTable(path string) (*RateTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ReadRatesCSV(f)
+	case ".json":
+		return ReadRatesJSON(f)
+	}
+	return nil, fmt.Errorf("rate file %s: unsupported extension", path)
+}
+
+// ReadRatesCSV reads rows of date,from,to,rate with a header line, e.g.
+//
+//	date,from,to,rate
+//	2024-05-02,EUR,RON,4.9731
+func ReadRatesCSV(r io.Reader) (*RateTable, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return NewRateTable(), nil
+	}
+
+	var rates []ExchangeRate
+	for i, row := range rows[1:] {
+		if len(row) != 4 {
+			return nil, fmt.Errorf("rates line %d: expected 4 fields, got %d", i+2, len(row))
+		}
+		rate, err := parseExchangeRate(row[0], row[1], row[2], row[3])
+		if err != nil {
+			return nil, fmt.Errorf("rates line %d: %w", i+2, err)
+		}
+		rates = append(rates, rate)
+	}
+	return NewRateTable(rates...), nil
+}
+
+// ReadRatesJSON reads an array of rates, e.g.
+//
+//	[{"date": "2024-05-02", "from": "EUR", "to": "RON", "rate": 4.9731}]
+func ReadRatesJSON(r io.Reader) (*RateTable, error) {
+	var rows []struct {
+		Date string      `json:"date"`
+		From string      `json:"from"`
+		To   string      `json:"to"`
+		Rate json.Number `json:"rate"`
+	}
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, err
+	}
+
+	var rates []ExchangeRate
+	for i, row := range rows {
+		rate, err := parseExchangeRate(row.Date, row.From, row.To, row.Rate.String())
+		if err != nil {
+			return nil, fmt.Errorf("rates entry %d: %w", i, err)
+		}
+		rates = append(rates, rate)
+	}
+	return NewRateTable(rates...), nil
+}
+
+// --- helpers ---
+
+func parseExchangeRate(date, from, to, rate string) (ExchangeRate, error) {
+	d, err := time.Parse(dateLayout, strings.TrimSpace(date))
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	r, err := ParseRate(rate)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	if r <= 0 {
+		return ExchangeRate{}, fmt.Errorf("rate %s must be positive", rate)
+	}
+	pair := CurrencyPair{strings.TrimSpace(from), strings.TrimSpace(to)}
+	for _, code := range []string{pair.From, pair.To} {
+		if _, err := LookupCurrency(code); err != nil {
+			return ExchangeRate{}, err
+		}
+	}
+	return ExchangeRate{Pair: pair, Rate: r, Date: d}, nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
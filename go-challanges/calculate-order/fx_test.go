@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestRateTable_PicksLatestOnOrBeforeDate(t *testing.T) {
+	for _, path := range []string{"testdata/rates.csv", "testdata/rates.json"} {
+		table, err := LoadRateTable(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		cases := []struct {
+			date     time.Time
+			wantRate string
+			wantDate string
+		}{
+			{day("2024-05-02"), "4.9731", "2024-05-02"},
+			{day("2024-05-03").Add(15 * time.Hour), "4.9765", "2024-05-03"},
+			{day("2024-05-05"), "4.9765", "2024-05-03"}, // weekend: Friday's rate
+			{day("2024-05-30"), "4.977", "2024-05-06"},
+			{time.Time{}, "4.977", "2024-05-06"},
+		}
+		for _, c := range cases {
+			fx, err := table.Rate("EUR", "RON", c.date)
+			if err != nil {
+				t.Errorf("%s %v: %v", path, c.date, err)
+				continue
+			}
+			if fx.Rate.String() != c.wantRate || fx.Date.Format(dateLayout) != c.wantDate {
+				t.Errorf("%s %v: got %s on %s, want %s on %s", path, c.date, fx.Rate, fx.Date.Format(dateLayout), c.wantRate, c.wantDate)
+			}
+		}
+
+		if _, err := table.Rate("EUR", "RON", day("2024-05-01")); !errors.Is(err, ErrRateNotFound) {
+			t.Errorf("%s: expected ErrRateNotFound before the first rate, got %v", path, err)
+		}
+		if _, err := table.Rate("RON", "EUR", day("2024-05-03")); !errors.Is(err, ErrRateNotFound) {
+			t.Errorf("%s: expected ErrRateNotFound for a pair with no rates, got %v", path, err)
+		}
+	}
+}
+
+func TestReadRatesCSV_Errors(t *testing.T) {
+	cases := []string{
+		"date,from,to,rate\n2024-05-02,EUR,RON\n",
+		"date,from,to,rate\n02/05/2024,EUR,RON,4.97\n",
+		"date,from,to,rate\n2024-05-02,EUR,XXX,4.97\n",
+		"date,from,to,rate\n2024-05-02,EUR,RON,0\n",
+	}
+	for _, in := range cases {
+		if _, err := ReadRatesCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("expected an error for %q", in)
+		}
+	}
+}
+
+func TestCalculateSummary_SettlementCurrency(t *testing.T) {
+	table, err := LoadRateTable("testdata/rates.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := Order{
+		Currency:           "USD",
+		Items:              []LineItem{{SKU: "BOOK", UnitPrice: 2000, Qty: 1}},
+		VATRate:            RateFromFloat(0.10),
+		PlatformFeePercent: RateFromFloat(0.10),
+		Date:               day("2024-05-04"),
+		SettlementCurrency: "EUR",
+		Rates:              table,
+	}
+
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Settlement{
+		Currency:        "EUR",
+		Rate:            RateFromFloat(0.9321),
+		RateDate:        day("2024-05-02"),
+		SellerPayout:    1678, // 18.00 USD * 0.9321 = 16.7778
+		PlatformRevenue: 186,  // 2.00 USD * 0.9321 = 1.8642
+	}
+	if got.Settlement != want {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got.Settlement, want)
+	}
+
+	o.SettlementCurrency = "RON"
+	if _, err := CalculateSummary(o); !errors.Is(err, ErrRateNotFound) {
+		t.Errorf("expected ErrRateNotFound for USD/RON, got %v", err)
+	}
+}
+
+func TestCalculateOrderSummary_RatesProvider(t *testing.T) {
+	in := OrderInput{
+		Currency: "EUR",
+		Items:    []Item{{SKU: "TSHIRT", UnitPrice: 50.00, Qty: 2}},
+		VATRate:  0.19,
+		Date:     day("2024-05-03"),
+		Rates: StaticRates{
+			AsOf:  day("2024-05-01"),
+			Rates: map[CurrencyPair]Rate{{"EUR", "RON"}: RateFromFloat(4.97)},
+		},
+	}
+
+	got := CalculateOrderSummary(in)
+	if got.SellerPayoutRON != 497 || got.SellerPayoutSettled != 497 || got.SettlementCurrency != "RON" {
+		t.Errorf("expected 497.00 RON, got %+v", got)
+	}
+	if got.ExchangeRate != 4.97 || !got.RateDate.Equal(day("2024-05-01")) {
+		t.Errorf("expected rate 4.97 as of 2024-05-01, got %v on %v", got.ExchangeRate, got.RateDate)
+	}
+}
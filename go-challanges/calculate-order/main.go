@@ -1,15 +1,22 @@
 package main
 
 import (
-	"math"
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
 )
 
 // --- data models ---
 
 type Item struct {
-	SKU       string
-	UnitPrice float64
-	Qty       int
+	SKU         string
+	UnitPrice   float64
+	Qty         int
+	TaxCategory TaxCategory // empty means TaxStandard
+	SellerID    string
 }
 
 type ProcessingFee struct {
@@ -22,10 +29,42 @@ type OrderInput struct {
 	VATRate            float64
 	ProcessingFee      ProcessingFee
 	PlatformFeePercent float64
+	SellerPlatformFees map[string]float64 // seller ID → platform fee percent, overrides PlatformFeePercent
 
 	// Step 3 additions
 	Currency      string
-	ExchangeRates map[string]float64 // e.g. {"EUR": 4.97, "RON": 1.0}
+	ExchangeRates map[string]float64 // RON per unit, e.g. {"EUR": 4.97, "RON": 1.0}; ignored when Rates is set
+
+	Rounding RoundingMode // zero value is HalfUp, matching the old math.Round
+
+	// Per-item VAT
+	TaxRates         map[TaxCategory]float64 // e.g. {TaxReduced: 0.09}; standard falls back to VATRate
+	PricesIncludeTax bool
+	TaxRounding      TaxRounding
+
+	// Discounts
+	Promotions  []PromotionInput
+	MaxDiscount float64
+
+	Validation Validation // Lenient keeps the original skip-and-clamp behaviour
+
+	// Settlement
+	Date               time.Time
+	SettlementCurrency string // defaults to RON
+	Rates              ExchangeRateProvider
+}
+
+// PromotionInput is the float form of Promotion.
+type PromotionInput struct {
+	Code    string
+	Kind    PromotionKind
+	SKU     string
+	Percent float64
+	Amount  float64
+	Buy     int
+	Get     int
+	Tiers   map[int]float64 // min qty → percent off
+	Cap     float64
 }
 
 type OrderSummary struct {
@@ -39,85 +78,236 @@ type OrderSummary struct {
 
 	// Step 3 additions
 	Currency           string
-	SellerPayoutRON    float64
-	PlatformRevenueRON float64
+	SellerPayoutRON    float64 // set when settling in RON
+	PlatformRevenueRON float64 // set when settling in RON
+
+	SettlementCurrency     string
+	ExchangeRate           float64
+	RateDate               time.Time
+	SellerPayoutSettled    float64
+	PlatformRevenueSettled float64
+
+	VATBreakdown []VATLine
+
+	Discount   float64
+	Promotions []PromotionLine
+
+	Sellers []SellerLine
+}
+
+type VATLine struct {
+	Rate float64
+	Net  float64
+	VAT  float64
+}
+
+type PromotionLine struct {
+	Code  string
+	SKU   string
+	Saved float64
+}
+
+type SellerLine struct {
+	SellerID            string
+	PlatformFeePercent  float64
+	Subtotal            float64
+	Discount            float64
+	VAT                 float64
+	TotalCollected      float64
+	ProcessingFee       float64
+	PlatformFee         float64
+	SellerPayout        float64
+	SellerPayoutSettled float64
 }
 
 // --- main logic ---
 
+// CalculateOrderSummary is the float API kept for existing callers. It
+// converts the input to exact Money, runs CalculateSummary and converts
+// the result back.
+//
+// It has no way to report an error, so every error yields an empty
+// OrderSummary, in Lenient mode too: an unknown currency code, a missing
+// exchange rate, a NaN or infinite amount, or any Strict field error.
+// Callers that must tell a rejected order from an empty one use
+// CalculateOrderSummaryE.
 func CalculateOrderSummary(in OrderInput) OrderSummary {
-	// --- compute subtotal with validation ---
-	subtotal := 0.0
-	for _, item := range in.Items {
-		if item.Qty > 0 && item.UnitPrice >= 0 {
-			subtotal += item.UnitPrice * float64(item.Qty)
-		}
+	s, _ := CalculateOrderSummaryE(in)
+	return s
+}
+
+// CalculateOrderSummaryE is CalculateOrderSummary with the error. Set
+// in.Validation to Strict to get a *FieldError for every bad field
+// instead of today's skipping and clamping.
+func CalculateOrderSummaryE(in OrderInput) (OrderSummary, error) {
+	o, err := in.exact()
+	if err != nil {
+		return OrderSummary{}, err
 	}
+	s, err := CalculateSummary(o)
+	if err != nil {
+		return OrderSummary{}, err
+	}
+	return o.floatSummary(s), nil
+}
+
+// --- float adapter ---
 
-	if subtotal == 0 {
-		return OrderSummary{}
-	}
-
-	// --- normalize inputs ---
-	vatRate := defaultIf(in.VATRate, 0.19, func(v float64) bool { return v <= 0 })
-	pfPercent := clampMin(in.ProcessingFee.Percent, 0)
-	pfFixed := clampMin(in.ProcessingFee.Fixed, 0)
-	platformFeePercent := clamp(in.PlatformFeePercent, 0, 1)
-
-	// --- main arithmetic (in order currency) ---
-	vat := subtotal * vatRate
-	total := subtotal + vat
-	processingFee := total*pfPercent + pfFixed
-	platformFee := subtotal * platformFeePercent
-	sellerPayout := total - processingFee - platformFee - vat
-
-	// --- currency rounding ---
-	subtotal = roundCurrency(subtotal, in.Currency)
-	vat = roundCurrency(vat, in.Currency)
-	total = roundCurrency(total, in.Currency)
-	processingFee = roundCurrency(processingFee, in.Currency)
-	platformFee = roundCurrency(platformFee, in.Currency)
-	sellerPayout = roundCurrency(sellerPayout, in.Currency)
-
-	// --- conversion to RON ---
-	rate := 1.0
-	if in.ExchangeRates != nil {
-		if r, ok := in.ExchangeRates[in.Currency]; ok && r > 0 {
-			rate = r
+// exact converts in to an Order. A NaN, an infinity or a float too large
+// for Money is a *FieldError wrapping ErrNotFinite in any Validation mode,
+// as there is nothing sensible to clamp it to.
+func (in OrderInput) exact() (Order, error) {
+	var errs []error
+	bad := func(index int, sku, field string) {
+		errs = append(errs, &FieldError{Index: index, SKU: sku, Field: field, Err: ErrNotFinite})
+	}
+	toMoney := func(field string, v float64) Money {
+		m, err := ParseMoney(formatFloat(v), minorUnits(in.Currency), in.Rounding)
+		if err != nil {
+			bad(-1, "", field)
+		}
+		return m
+	}
+	toRate := func(field string, v float64) Rate {
+		r, err := ParseRate(formatFloat(v))
+		if err != nil {
+			bad(-1, "", field)
 		}
+		return r
 	}
-	sellerPayoutRON := round2(roundCurrency(sellerPayout, in.Currency) * rate)
-	platformRevenueRON := round2(roundCurrency(platformFee, in.Currency) * rate)
 
-	// --- return summary ---
-	return OrderSummary{
+	o := Order{
+		VATRate: toRate("VATRate", in.VATRate),
+		ProcessingFee: Fee{
+			Percent: toRate("ProcessingFee.Percent", in.ProcessingFee.Percent),
+			Fixed:   toMoney("ProcessingFee.Fixed", in.ProcessingFee.Fixed),
+		},
+		PlatformFeePercent: toRate("PlatformFeePercent", in.PlatformFeePercent),
 		Currency:           in.Currency,
-		Subtotal:           subtotal,
-		VAT:                vat,
-		TotalCollected:     total,
-		ProcessingFee:      processingFee,
-		PlatformFee:        platformFee,
-		SellerPayout:       sellerPayout,
-		PlatformRevenue:    platformFee,
-		SellerPayoutRON:    round2(sellerPayoutRON),
-		PlatformRevenueRON: round2(platformRevenueRON),
+		Rounding:           in.Rounding,
+		PricesIncludeTax:   in.PricesIncludeTax,
+		TaxRounding:        in.TaxRounding,
+		MaxDiscount:        toMoney("MaxDiscount", in.MaxDiscount),
+		Validation:         in.Validation,
+		Date:               in.Date,
+		SettlementCurrency: in.SettlementCurrency,
+		Rates:              in.Rates,
+	}
+	for i, item := range in.Items {
+		unit, total, err := ParseLinePrice(formatFloat(item.UnitPrice), item.Qty, minorUnits(in.Currency), in.Rounding)
+		if err != nil {
+			bad(i, item.SKU, "UnitPrice")
+		}
+		o.Items = append(o.Items, LineItem{
+			SKU:         item.SKU,
+			UnitPrice:   unit,
+			Qty:         item.Qty,
+			TaxCategory: item.TaxCategory,
+			SellerID:    item.SellerID,
+			Total:       total,
+		})
+	}
+	for i, p := range in.Promotions {
+		field := func(name string) string { return fmt.Sprintf("Promotions[%d].%s", i, name) }
+		promo := Promotion{
+			Code:    p.Code,
+			Kind:    p.Kind,
+			SKU:     p.SKU,
+			Percent: toRate(field("Percent"), p.Percent),
+			Amount:  toMoney(field("Amount"), p.Amount),
+			Buy:     p.Buy,
+			Get:     p.Get,
+			Cap:     toMoney(field("Cap"), p.Cap),
+		}
+		for _, minQty := range slices.Sorted(maps.Keys(p.Tiers)) {
+			pct := toRate(field(fmt.Sprintf("Tiers[%d]", minQty)), p.Tiers[minQty])
+			promo.Tiers = append(promo.Tiers, VolumeTier{MinQty: minQty, Percent: pct})
+		}
+		o.Promotions = append(o.Promotions, promo)
+	}
+	if in.TaxRates != nil {
+		o.TaxRates = make(map[TaxCategory]Rate, len(in.TaxRates))
+		for _, c := range slices.Sorted(maps.Keys(in.TaxRates)) {
+			o.TaxRates[c] = toRate(fmt.Sprintf("TaxRates[%s]", c), in.TaxRates[c])
+		}
+	}
+	if in.SellerPlatformFees != nil {
+		o.SellerPlatformFees = make(map[string]Rate, len(in.SellerPlatformFees))
+		for _, id := range slices.Sorted(maps.Keys(in.SellerPlatformFees)) {
+			o.SellerPlatformFees[id] = toRate(fmt.Sprintf("SellerPlatformFees[%s]", id), in.SellerPlatformFees[id])
+		}
 	}
+	if in.Rates == nil && in.ExchangeRates != nil {
+		static := StaticRates{Rates: make(map[CurrencyPair]Rate, len(in.ExchangeRates))}
+		for _, code := range slices.Sorted(maps.Keys(in.ExchangeRates)) {
+			static.Rates[CurrencyPair{code, "RON"}] = toRate(fmt.Sprintf("ExchangeRates[%s]", code), in.ExchangeRates[code])
+		}
+		o.Rates = static
+	}
+	return o, errors.Join(errs...)
 }
 
-// --- helpers ---
-
-func round2(v float64) float64 {
-	return math.Round(v*100) / 100
+func (o Order) floatSummary(s Summary) OrderSummary {
+	exp, settleExp := minorUnits(o.Currency), minorUnits(s.Settlement.Currency)
+	var breakdown []VATLine
+	for _, b := range s.VATBreakdown {
+		breakdown = append(breakdown, VATLine{Rate: b.Rate.Float64(), Net: b.Net.Float64(exp), VAT: b.VAT.Float64(exp)})
+	}
+	var promotions []PromotionLine
+	for _, p := range s.Promotions {
+		promotions = append(promotions, PromotionLine{Code: p.Code, SKU: p.SKU, Saved: p.Saved.Float64(exp)})
+	}
+	var sellers []SellerLine
+	for _, sh := range s.Sellers {
+		sellers = append(sellers, SellerLine{
+			SellerID:            sh.SellerID,
+			PlatformFeePercent:  sh.PlatformFeePercent.Float64(),
+			Subtotal:            sh.Subtotal.Float64(exp),
+			Discount:            sh.Discount.Float64(exp),
+			VAT:                 sh.VAT.Float64(exp),
+			TotalCollected:      sh.TotalCollected.Float64(exp),
+			ProcessingFee:       sh.ProcessingFee.Float64(exp),
+			PlatformFee:         sh.PlatformFee.Float64(exp),
+			SellerPayout:        sh.SellerPayout.Float64(exp),
+			SellerPayoutSettled: sh.SettledPayout.Float64(settleExp),
+		})
+	}
+	out := OrderSummary{
+		Currency:               s.Currency,
+		Subtotal:               s.Subtotal.Float64(exp),
+		VAT:                    s.VAT.Float64(exp),
+		TotalCollected:         s.TotalCollected.Float64(exp),
+		ProcessingFee:          s.ProcessingFee.Float64(exp),
+		PlatformFee:            s.PlatformFee.Float64(exp),
+		SellerPayout:           s.SellerPayout.Float64(exp),
+		PlatformRevenue:        s.PlatformRevenue.Float64(exp),
+		SettlementCurrency:     s.Settlement.Currency,
+		ExchangeRate:           s.Settlement.Rate.Float64(),
+		RateDate:               s.Settlement.RateDate,
+		SellerPayoutSettled:    s.Settlement.SellerPayout.Float64(settleExp),
+		PlatformRevenueSettled: s.Settlement.PlatformRevenue.Float64(settleExp),
+		VATBreakdown:           breakdown,
+		Discount:               s.Discount.Float64(exp),
+		Promotions:             promotions,
+		Sellers:                sellers,
+	}
+	if s.Settlement.Currency == "RON" {
+		out.SellerPayoutRON = out.SellerPayoutSettled
+		out.PlatformRevenueRON = out.PlatformRevenueSettled
+	}
+	return out
 }
 
+// --- helpers ---
+
+// roundCurrency rounds a float amount to the currency's minor units, for
+// callers that still hold floats.
 func roundCurrency(v float64, currency string) float64 {
-	if currency == "JPY" {
-		return math.Round(v) // 0 decimals
-	}
-	return round2(v) // 2 decimals
+	exp := minorUnits(currency)
+	return MoneyFromFloat(v, exp, HalfUp).Float64(exp)
 }
 
-func clamp(v, min, max float64) float64 {
+func clamp[T cmp.Ordered](v, min, max T) T {
 	if v < min {
 		return min
 	}
@@ -127,14 +317,14 @@ func clamp(v, min, max float64) float64 {
 	return v
 }
 
-func clampMin(v, min float64) float64 {
+func clampMin[T cmp.Ordered](v, min T) T {
 	if v < min {
 		return min
 	}
 	return v
 }
 
-func defaultIf(v, def float64, invalid func(float64) bool) float64 {
+func defaultIf[T any](v, def T, invalid func(T) bool) T {
 	if invalid(v) {
 		return def
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"math"
 	"testing"
 )
@@ -200,21 +201,13 @@ func TestCalculateOrderSummary_MissingExchangeRate(t *testing.T) {
 		PlatformFeePercent: 0.10,
 	}
 
-	got := CalculateOrderSummary(in)
-	want := OrderSummary{
-		Currency:           "USD",
-		Subtotal:           20.00,
-		VAT:                2.00,
-		TotalCollected:     22.00,
-		ProcessingFee:      2.10,
-		PlatformFee:        2.00,
-		SellerPayout:       15.90,
-		PlatformRevenue:    2.00,
-		SellerPayoutRON:    15.90, // since missing USD rate → assume 1.0
-		PlatformRevenueRON: 2.00,
+	// a missing USD rate is an error, no longer an assumed 1.0
+	got, err := CalculateOrderSummaryE(in)
+	if !errors.Is(err, ErrRateNotFound) {
+		t.Fatalf("expected ErrRateNotFound, got %v", err)
 	}
-
-	assertEqual(t, got, want)
+	assertEqual(t, got, OrderSummary{})
+	assertEqual(t, CalculateOrderSummary(in), OrderSummary{})
 }
 
 func assertEqual(t *testing.T, got, want OrderSummary) {
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount in the minor units of its currency
+// (cents for EUR/RON, yen for JPY). The currency travels next to it.
+type Money int64
+
+// Rate is an exact decimal multiplier (VAT rate, fee percent, exchange
+// rate) stored with RateDecimals implied decimal places.
+type Rate int64
+
+const (
+	RateDecimals = 9
+	RateScale    = Rate(1_000_000_000)
+)
+
+// RoundingMode decides what happens to a result that falls exactly
+// halfway between two minor units.
+type RoundingMode int
+
+const (
+	HalfUp   RoundingMode = iota // ties away from zero, same as math.Round
+	HalfEven                     // ties to the even neighbour (banker's rounding)
+)
+
+func (m RoundingMode) String() string {
+	switch m {
+	case HalfUp:
+		return "half-up"
+	case HalfEven:
+		return "half-even"
+	}
+	return fmt.Sprintf("RoundingMode(%d)", int(m))
+}
+
+// ErrNotFinite is a float input that has no Money or Rate value.
+var ErrNotFinite = errors.New("must be a finite number in range")
+
+// --- constructors ---
+
+// ParseMoney parses a decimal string such as "12.345" into minor units
+// with exp decimals, rounding any extra digits with mode.
+func ParseMoney(s string, exp int, mode RoundingMode) (Money, error) {
+	v, err := parseDecimal(s, exp, mode)
+	if err != nil {
+		return 0, err
+	}
+	return Money(v), nil
+}
+
+// MoneyFromFloat converts a float amount using its shortest decimal
+// representation, so 1.005 is treated as the decimal 1.005 and not as
+// the binary value just below it. NaN, infinities and amounts out of
+// range give 0; use ParseMoney to tell them apart.
+func MoneyFromFloat(v float64, exp int, mode RoundingMode) Money {
+	m, err := ParseMoney(formatFloat(v), exp, mode)
+	if err != nil {
+		return 0
+	}
+	return m
+}
+
+// ParseLinePrice parses the unit price of qty units. A price finer than a
+// minor unit, such as 0.125 RON, also gives the line total rounded once
+// with mode; total is 0 otherwise, see LineItem.Total.
+func ParseLinePrice(s string, qty, exp int, mode RoundingMode) (unit, total Money, err error) {
+	unit, err = ParseMoney(s, exp, mode)
+	if err != nil {
+		return 0, 0, err
+	}
+	exact, err := ParseRate(s)
+	if err != nil || exp >= RateDecimals || int64(exact)%pow10(RateDecimals-exp).Int64() == 0 {
+		return unit, 0, nil // too large for a Rate, or whole minor units
+	}
+	return unit, Money(qty).Convert(exact, 0, exp, mode), nil
+}
+
+// ParseRate parses a decimal multiplier such as "0.19" or "4.97".
+func ParseRate(s string) (Rate, error) {
+	v, err := parseDecimal(s, RateDecimals, HalfUp)
+	if err != nil {
+		return 0, err
+	}
+	return Rate(v), nil
+}
+
+// RateFromFloat converts a float multiplier the same way MoneyFromFloat
+// does, giving 0 for the same inputs.
+func RateFromFloat(v float64) Rate {
+	r, err := ParseRate(formatFloat(v))
+	if err != nil {
+		return 0
+	}
+	return r
+}
+
+// --- arithmetic ---
+
+func (m Money) Mul(qty int) Money {
+	return m * Money(qty)
+}
+
+// MulRate returns m*r rounded to a whole minor unit with mode.
+func (m Money) MulRate(r Rate, mode RoundingMode) Money {
+	return m.MulFrac(r, RateScale, mode)
+}
+
+// MulFrac returns m*num/den rounded to a whole minor unit with mode, e.g.
+// the VAT inside a gross price is gross.MulFrac(r, RateScale+r, mode).
+func (m Money) MulFrac(num, den Rate, mode RoundingMode) Money {
+	n := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(int64(num)))
+	return Money(quoRound(n, big.NewInt(int64(den)), mode).Int64())
+}
+
+// Prorate returns the share of m that part is of whole, rounded with
+// mode. It returns m itself when part == whole.
+func (m Money) Prorate(part, whole Money, mode RoundingMode) Money {
+	if whole == 0 {
+		return 0
+	}
+	n := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(int64(part)))
+	d := big.NewInt(int64(whole))
+	if d.Sign() < 0 {
+		n.Neg(n)
+		d.Neg(d)
+	}
+	return Money(quoRound(n, d, mode).Int64())
+}
+
+// Convert turns an amount with fromExp decimals into another currency
+// with toExp decimals at the given rate, rounding once at the end.
+func (m Money) Convert(r Rate, fromExp, toExp int, mode RoundingMode) Money {
+	num := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(int64(r)))
+	den := big.NewInt(int64(RateScale))
+	if toExp > fromExp {
+		num.Mul(num, pow10(toExp-fromExp))
+	} else {
+		den.Mul(den, pow10(fromExp-toExp))
+	}
+	return Money(quoRound(num, den, mode).Int64())
+}
+
+func (r Rate) Float64() float64 {
+	return float64(r) / float64(RateScale)
+}
+
+func (r Rate) String() string {
+	return formatDecimal(int64(r), RateDecimals, true)
+}
+
+// allocate splits total across weights in proportion to their size,
+// flooring every share and handing the leftover minor units one at a time
+// to the largest weights first (lowest index on a tie). The shares always
+// sum to total and all have its sign; when every weight is zero the first
+// share takes it all.
+func allocate(total Money, weights []Money) []Money {
+	shares := make([]Money, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+	var sum Money
+	for _, w := range weights {
+		sum += abs(w)
+	}
+	if sum == 0 {
+		shares[0] = total
+		return shares
+	}
+
+	sign := Money(1)
+	if total < 0 {
+		sign, total = -1, -total
+	}
+	left := total
+	for i, w := range weights {
+		n := new(big.Int).Mul(big.NewInt(int64(total)), big.NewInt(int64(abs(w))))
+		shares[i] = Money(n.Div(n, big.NewInt(int64(sum))).Int64())
+		left -= shares[i]
+	}
+	spread(shares, weights, left)
+
+	for i := range shares {
+		shares[i] *= sign
+	}
+	return shares
+}
+
+// spread adds left, one minor unit at a time, to the shares of the
+// largest weights first (lowest index on a tie). A negative left is taken
+// away the same way.
+func spread(shares, weights []Money, left Money) {
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(abs(weights[b]), abs(weights[a])) })
+	unit := Money(1)
+	if left < 0 {
+		unit, left = -1, -left
+	}
+	for i := 0; left > 0; i = (i + 1) % len(order) {
+		shares[order[i]] += unit
+		left--
+	}
+}
+
+// --- formatting ---
+
+func (m Money) Float64(exp int) float64 {
+	return float64(m) / math.Pow10(exp)
+}
+
+// Format renders m with exactly exp decimals, e.g. "206.52".
+func (m Money) Format(exp int) string {
+	return formatDecimal(int64(m), exp, false)
+}
+
+// --- helpers ---
+
+var bigOne = big.NewInt(1)
+
+// quoRound returns num/den rounded to an integer with mode. den must be positive.
+func quoRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	c := twice.Cmp(den)
+	if c > 0 || (c == 0 && (mode == HalfUp || q.Bit(0) == 1)) {
+		if num.Sign() < 0 {
+			q.Sub(q, bigOne)
+		} else {
+			q.Add(q, bigOne)
+		}
+	}
+	return q
+}
+
+func abs(m Money) Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseDecimal reads a plain decimal string and returns it scaled to
+// exp decimals.
+func parseDecimal(s string, exp int, mode RoundingMode) (int64, error) {
+	s = strings.TrimSpace(s)
+	digits, neg := strings.CutPrefix(s, "-")
+	if !neg {
+		digits = strings.TrimPrefix(digits, "+")
+	}
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" || !allDigits(intPart) || !allDigits(fracPart) {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	num, _ := new(big.Int).SetString(intPart+fracPart, 10)
+	if neg {
+		num.Neg(num)
+	}
+	den := big.NewInt(1)
+	if len(fracPart) > exp {
+		den = pow10(len(fracPart) - exp)
+	} else {
+		num.Mul(num, pow10(exp-len(fracPart)))
+	}
+
+	q := quoRound(num, den, mode)
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("decimal %q out of range", s)
+	}
+	return q.Int64(), nil
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func formatDecimal(v int64, exp int, trim bool) string {
+	sign := ""
+	u := new(big.Int).SetInt64(v)
+	if u.Sign() < 0 {
+		sign = "-"
+		u.Abs(u)
+	}
+	s := u.String()
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	intPart, fracPart := s[:len(s)-exp], s[len(s)-exp:]
+	if trim {
+		fracPart = strings.TrimRight(fracPart, "0")
+		if fracPart == "" {
+			return sign + intPart
+		}
+	}
+	return sign + intPart + "." + fracPart
+}
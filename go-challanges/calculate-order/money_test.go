@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in   string
+		exp  int
+		mode RoundingMode
+		want Money
+	}{
+		{"206.52", 2, HalfUp, 20652},
+		{"-6.9", 2, HalfUp, -690},
+		{"1.005", 2, HalfUp, 101},
+		{"1.005", 2, HalfEven, 100},
+		{"1.015", 2, HalfEven, 102},
+		{"-1.005", 2, HalfUp, -101},
+		{"-1.005", 2, HalfEven, -100},
+		{"1500.5", 0, HalfUp, 1501},
+		{"1500.5", 0, HalfEven, 1500},
+		{".5", 3, HalfUp, 500},
+	}
+	for _, c := range cases {
+		got, err := ParseMoney(c.in, c.exp, c.mode)
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseMoney(%q, %d, %v) = %d, want %d", c.in, c.exp, c.mode, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "1.2.3", "--1", "1e5"} {
+		if _, err := ParseMoney(bad, 2, HalfUp); err == nil {
+			t.Errorf("ParseMoney(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestMoneyFromFloatUsesDecimalValue(t *testing.T) {
+	// 1.005 is stored as 1.00499999999999989... in binary; math.Round
+	// would give 1.00, the decimal value rounds half-up to 1.01.
+	if got := MoneyFromFloat(1.005, 2, HalfUp); got != 101 {
+		t.Fatalf("expected 101, got %d", got)
+	}
+}
+
+func TestOrderInput_ExactRejectsNonFinite(t *testing.T) {
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e300} {
+		in := OrderInput{Items: []Item{{SKU: "a", UnitPrice: v, Qty: 1}}, VATRate: 0.19, Currency: "RON"}
+		if _, err := in.exact(); !errors.Is(err, ErrNotFinite) {
+			t.Errorf("%v: expected ErrNotFinite, got %v", v, err)
+		}
+		if got := CalculateOrderSummary(in); !reflect.DeepEqual(got, OrderSummary{}) {
+			t.Errorf("%v: expected an empty summary, got %+v", v, got)
+		}
+	}
+}
+
+func TestCalculateOrderSummary_SubMinorUnitPrice(t *testing.T) {
+	// 0.125 RON a unit: rounding each unit first would give 130.00 with
+	// half-up and 120.00 with half-even
+	for _, mode := range []RoundingMode{HalfUp, HalfEven} {
+		in := OrderInput{
+			Items:    []Item{{SKU: "SCREW", UnitPrice: 0.125, Qty: 1000}},
+			VATRate:  0.19,
+			Currency: "RON",
+			Rounding: mode,
+		}
+		if got := CalculateOrderSummary(in); got.Subtotal != 125 || got.VAT != 23.75 {
+			t.Errorf("%v: expected subtotal 125 and VAT 23.75, got %+v", mode, got)
+		}
+	}
+
+	type testCase struct {
+		price    string
+		qty      int
+		expUnit  Money
+		expTotal Money
+	}
+	for _, tc := range []testCase{
+		{"0.125", 1000, 13, 12500},
+		{"0.125", 3, 13, 38},
+		{"19.99", 3, 1999, 0},
+		{"5", 2, 500, 0},
+	} {
+		unit, total, err := ParseLinePrice(tc.price, tc.qty, 2, HalfUp)
+		if err != nil || unit != tc.expUnit || total != tc.expTotal {
+			t.Errorf("ParseLinePrice(%s, %d): expected %d/%d, got %d/%d (%v)", tc.price, tc.qty, tc.expUnit, tc.expTotal, unit, total, err)
+		}
+	}
+}
+
+func TestMulRateAndConvert(t *testing.T) {
+	if got := Money(24000).MulRate(RateFromFloat(0.19), HalfUp); got != 4560 {
+		t.Errorf("VAT: expected 4560, got %d", got)
+	}
+	if got := Money(250).MulRate(RateFromFloat(0.05), HalfEven); got != 12 {
+		t.Errorf("half-even 12.5: expected 12, got %d", got)
+	}
+	if got := Money(250).MulRate(RateFromFloat(0.05), HalfUp); got != 13 {
+		t.Errorf("half-up 12.5: expected 13, got %d", got)
+	}
+	// 94.95 EUR * 4.97 = 471.9015 RON
+	if got := Money(9495).Convert(RateFromFloat(4.97), 2, 2, HalfUp); got != 47190 {
+		t.Errorf("EUR->RON: expected 47190, got %d", got)
+	}
+	// 1000 JPY * 0.031 = 31.00 RON
+	if got := Money(1000).Convert(RateFromFloat(0.031), 0, 2, HalfUp); got != 3100 {
+		t.Errorf("JPY->RON: expected 3100, got %d", got)
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	cases := []struct{ got, want string }{
+		{Money(20652).Format(2), "206.52"},
+		{Money(-690).Format(2), "-6.90"},
+		{Money(5).Format(2), "0.05"},
+		{Money(1500).Format(0), "1500"},
+		{RateFromFloat(0.029).String(), "0.029"},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("expected %q, got %q", c.want, c.got)
+		}
+	}
+}
+
+func TestCalculateSummary_Reconciles(t *testing.T) {
+	o := Order{
+		Items: []LineItem{
+			{SKU: "A", UnitPrice: 333, Qty: 7},
+			{SKU: "B", UnitPrice: 1999, Qty: 3},
+		},
+		VATRate:            RateFromFloat(0.19),
+		ProcessingFee:      Fee{Percent: RateFromFloat(0.029), Fixed: 120},
+		PlatformFeePercent: RateFromFloat(0.125),
+		Currency:           "RON",
+		Rounding:           HalfEven,
+	}
+
+	s, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Subtotal != 333*7+1999*3 {
+		t.Errorf("unexpected subtotal %d", s.Subtotal)
+	}
+	if s.SellerPayout+s.ProcessingFee+s.PlatformFee+s.VAT != s.TotalCollected {
+		t.Errorf("summary does not reconcile: %+v", s)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+)
+
+// --- exact data models ---
+
+// LineItem is the exact counterpart of Item: UnitPrice is in the minor
+// units of Order.Currency.
+type LineItem struct {
+	SKU         string
+	UnitPrice   Money
+	Qty         int
+	TaxCategory TaxCategory
+	SellerID    string
+
+	// Total is the line amount when the unit price is finer than a minor
+	// unit, so the line is rounded once rather than each unit; 0 means
+	// UnitPrice×Qty. ParseLinePrice sets both.
+	Total Money
+}
+
+type Fee struct {
+	Percent Rate
+	Fixed   Money
+}
+
+type Order struct {
+	Items              []LineItem
+	VATRate            Rate
+	ProcessingFee      Fee
+	PlatformFeePercent Rate
+	SellerPlatformFees map[string]Rate // per seller, overrides PlatformFeePercent
+
+	Currency string
+	Rounding RoundingMode
+
+	Date               time.Time            // picks the exchange rate
+	SettlementCurrency string               // defaults to RON
+	Rates              ExchangeRateProvider // may be nil when Currency is the settlement currency
+
+	TaxRates         map[TaxCategory]Rate // overrides VATRate for TaxStandard when set
+	PricesIncludeTax bool                 // UnitPrice is gross
+	TaxRounding      TaxRounding
+
+	Promotions  []Promotion
+	MaxDiscount Money // cap on all promotions together, 0 means uncapped
+
+	Validation Validation
+}
+
+// Summary holds every amount in the minor units of Currency, except
+// Settlement which uses the settlement currency's.
+type Summary struct {
+	Subtotal        Money
+	VAT             Money
+	TotalCollected  Money
+	ProcessingFee   Money
+	PlatformFee     Money
+	SellerPayout    Money
+	PlatformRevenue Money
+
+	Currency   string
+	Settlement Settlement
+
+	VATBreakdown []VATBucket
+
+	Discount   Money // total saved by Promotions, already taken off Subtotal
+	Promotions []AppliedPromotion
+
+	Sellers []SellerShare // sorted by SellerID, one entry "" for single-seller orders
+}
+
+// Settlement is what the seller and the platform receive after
+// conversion, and the rate used to get there.
+type Settlement struct {
+	Currency        string
+	Rate            Rate
+	RateDate        time.Time
+	SellerPayout    Money
+	PlatformRevenue Money
+}
+
+// --- main logic ---
+
+// CalculateSummary is the exact implementation behind CalculateOrderSummary.
+//
+// Promotions are taken off the lines first, so VAT and the platform fee
+// are charged on the discounted amounts.
+//
+// Rounding happens only at these points, each with o.Rounding:
+//  0. PercentOff and VolumeDiscount savings
+//  1. VAT, once per rate or once per line depending on o.TaxRounding
+//  2. the percentage part of the processing fee (the fixed part is exact)
+//  3. the platform fee on each seller's subtotal
+//  4. each conversion to the settlement currency
+//
+// Everything else is integer addition and subtraction, so
+// SellerPayout + ProcessingFee + PlatformFee + VAT == TotalCollected always.
+// Splitting between sellers allocates whole minor units and never rounds,
+// see sellerShares.
+//
+// o.Currency must be in the currency registry; its exponent decides how
+// many decimals the Money values carry. In Strict mode any invalid field
+// fails the whole order (see FieldErrors); in Lenient mode bad items are
+// skipped and out-of-range fees are clamped.
+func CalculateSummary(o Order) (Summary, error) {
+	if o.Validation == Strict {
+		if err := o.validate(); err != nil {
+			return Summary{}, err
+		}
+	}
+
+	cur, err := LookupCurrency(o.Currency)
+	if err != nil {
+		return Summary{}, err
+	}
+	settle, err := LookupCurrency(cmp.Or(o.SettlementCurrency, "RON"))
+	if err != nil {
+		return Summary{}, err
+	}
+
+	// --- compute subtotal with validation ---
+	lines, gross := o.billableLines()
+
+	if gross == 0 {
+		if o.Validation == Strict {
+			return Summary{}, &FieldError{Index: -1, Field: "Items", Err: ErrNoBillableItems}
+		}
+		return Summary{}, nil
+	}
+
+	// --- normalize inputs ---
+	vatRate := o.standardVATRate()
+	pfPercent := clampMin(o.ProcessingFee.Percent, 0)
+	pfFixed := clampMin(o.ProcessingFee.Fixed, 0)
+
+	// --- main arithmetic (in order currency) ---
+	mode := o.Rounding
+	promotions, err := applyPromotions(o, lines)
+	if err != nil {
+		return Summary{}, err
+	}
+	var discount Money
+	for _, p := range promotions {
+		discount += p.Saved
+	}
+	breakdown, err := vatBreakdown(o, lines, vatRate)
+	if err != nil {
+		return Summary{}, err
+	}
+	var subtotal, vat Money
+	for _, b := range breakdown {
+		subtotal += b.Net
+		vat += b.VAT
+	}
+	total := subtotal + vat
+	processingFee := total.MulRate(pfPercent, mode) + pfFixed
+
+	sellers, err := sellerShares(o, lines, breakdown, processingFee)
+	if err != nil {
+		return Summary{}, err
+	}
+	var platformFee Money
+	for _, sh := range sellers {
+		platformFee += sh.PlatformFee
+	}
+	sellerPayout := total - processingFee - platformFee - vat
+
+	// --- conversion to settlement currency ---
+	fx, err := o.exchangeRate(settle.Code)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	// --- return summary ---
+	st := Settlement{
+		Currency:        settle.Code,
+		Rate:            fx.Rate,
+		RateDate:        fx.Date,
+		SellerPayout:    sellerPayout.Convert(fx.Rate, cur.Exponent, settle.Exponent, mode),
+		PlatformRevenue: platformFee.Convert(fx.Rate, cur.Exponent, settle.Exponent, mode),
+	}
+	settleShares(sellers, st, cur.Exponent, settle.Exponent, mode)
+	return Summary{
+		Currency:        o.Currency,
+		Subtotal:        subtotal,
+		VAT:             vat,
+		TotalCollected:  total,
+		ProcessingFee:   processingFee,
+		PlatformFee:     platformFee,
+		SellerPayout:    sellerPayout,
+		PlatformRevenue: platformFee,
+		Settlement:      st,
+		VATBreakdown:    breakdown,
+		Discount:        discount,
+		Promotions:      promotions,
+		Sellers:         sellers,
+	}, nil
+}
+
+// exchangeRate returns the rate from o.Currency to the settlement currency
+// on o.Date. A missing rate is an error, never an implicit 1.0.
+func (o Order) exchangeRate(to string) (ExchangeRate, error) {
+	if o.Currency == to {
+		return ExchangeRate{Pair: CurrencyPair{to, to}, Rate: RateScale, Date: o.Date}, nil
+	}
+	if o.Rates == nil {
+		return ExchangeRate{}, fmt.Errorf("%w for %s/%s: no rate provider", ErrRateNotFound, o.Currency, to)
+	}
+	fx, err := o.Rates.Rate(o.Currency, to, o.Date)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	if fx.Rate <= 0 {
+		return ExchangeRate{}, fmt.Errorf("%w for %s/%s: rate %s is not positive", ErrRateNotFound, o.Currency, to, fx.Rate)
+	}
+	return fx, nil
+}
+
+// billableLines returns the items with a positive quantity and a
+// non-negative price, and their total before promotions.
+func (o Order) billableLines() ([]line, Money) {
+	var (
+		lines []line
+		gross Money
+	)
+	for _, item := range o.Items {
+		if item.Qty > 0 && item.UnitPrice >= 0 && item.Total >= 0 {
+			amount := item.amount()
+			lines = append(lines, line{LineItem: item, Amount: amount})
+			gross += amount
+		}
+	}
+	return lines, gross
+}
+
+// amount is the line before promotions.
+func (l LineItem) amount() Money {
+	if l.Total != 0 {
+		return l.Total
+	}
+	return l.UnitPrice.Mul(l.Qty)
+}
+
+func (o Order) standardVATRate() Rate {
+	return defaultIf(o.VATRate, RateFromFloat(0.19), func(v Rate) bool { return v <= 0 })
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+type PromotionKind int
+
+const (
+	PercentOff     PromotionKind = iota // Percent off the order, or off SKU when set
+	AmountOff                           // fixed Amount off the order, or off SKU when set
+	BuyXGetY                            // for every Buy+Get units of SKU, Get units are free
+	VolumeDiscount                      // Percent off SKU from the highest tier its Qty reaches
+)
+
+type VolumeTier struct {
+	MinQty  int
+	Percent Rate
+}
+
+// Promotion is a coupon or an automatic promotion.
+//
+// Stacking rule, applied in this order:
+//  1. BuyXGetY and VolumeDiscount are line promotions. They do not stack
+//     with each other: each line gets the single one saving the most
+//     (the first listed on a tie).
+//  2. PercentOff coupons, in the order listed, each on what is left after
+//     the previous steps.
+//  3. AmountOff coupons, in the order listed, never taking a line below 0.
+//
+// Cap limits a single promotion, Order.MaxDiscount limits all of them
+// together; once it is used up the remaining promotions save nothing.
+type Promotion struct {
+	Code    string
+	Kind    PromotionKind
+	SKU     string
+	Percent Rate
+	Amount  Money
+	Buy     int
+	Get     int
+	Tiers   []VolumeTier
+	Cap     Money // 0 means uncapped
+}
+
+type AppliedPromotion struct {
+	Code  string
+	SKU   string // empty for order-wide coupons
+	Saved Money
+}
+
+// line is a valid item with its amount after promotions.
+type line struct {
+	LineItem
+	Amount Money
+}
+
+// --- main logic ---
+
+// applyPromotions lowers the Amount of lines in place and returns every
+// promotion that saved something, in the order it was applied.
+func applyPromotions(o Order, lines []line) ([]AppliedPromotion, error) {
+	for _, p := range o.Promotions {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var applied []AppliedPromotion
+	budget := o.MaxDiscount
+	spend := func(p Promotion, sku string, saving Money) Money {
+		if p.Cap > 0 {
+			saving = min(saving, p.Cap-savedBy(applied, p.Code))
+		}
+		if o.MaxDiscount > 0 {
+			saving = min(saving, budget)
+			budget -= max(saving, 0)
+		}
+		if saving <= 0 {
+			return 0
+		}
+		i := slices.IndexFunc(applied, func(a AppliedPromotion) bool { return a.Code == p.Code && a.SKU == sku })
+		if i < 0 {
+			applied = append(applied, AppliedPromotion{Code: p.Code, SKU: sku})
+			i = len(applied) - 1
+		}
+		applied[i].Saved += saving
+		return saving
+	}
+
+	// --- 1. line promotions ---
+	for i := range lines {
+		l := &lines[i]
+		var (
+			best    Promotion
+			bestAmt Money
+		)
+		for _, p := range o.Promotions {
+			if p.SKU != l.SKU || (p.Kind != BuyXGetY && p.Kind != VolumeDiscount) {
+				continue
+			}
+			if s := p.lineSaving(l, o.Rounding); s > bestAmt {
+				best, bestAmt = p, s
+			}
+		}
+		if bestAmt > 0 {
+			l.Amount -= spend(best, l.SKU, bestAmt)
+		}
+	}
+
+	// --- 2. and 3. coupons ---
+	for _, kind := range []PromotionKind{PercentOff, AmountOff} {
+		for _, p := range o.Promotions {
+			if p.Kind != kind {
+				continue
+			}
+			idx := p.matching(lines)
+			weights := make([]Money, len(idx))
+			var base Money
+			for j, i := range idx {
+				weights[j] = lines[i].Amount
+				base += lines[i].Amount
+			}
+
+			saving := min(p.Amount, base)
+			if kind == PercentOff {
+				saving = base.MulRate(p.Percent, o.Rounding)
+			}
+			saving = spend(p, p.SKU, saving)
+			for j, share := range allocate(saving, weights) {
+				lines[idx[j]].Amount -= share
+			}
+		}
+	}
+
+	return applied, nil
+}
+
+func (p Promotion) lineSaving(l *line, mode RoundingMode) Money {
+	switch p.Kind {
+	case BuyXGetY:
+		free := l.Qty / (p.Buy + p.Get) * p.Get
+		return min(l.amount().Prorate(Money(free), Money(l.Qty), mode), l.Amount)
+	case VolumeDiscount:
+		var pct Rate
+		for _, t := range p.Tiers {
+			if l.Qty >= t.MinQty && t.Percent > pct {
+				pct = t.Percent
+			}
+		}
+		return l.Amount.MulRate(pct, mode)
+	}
+	return 0
+}
+
+// matching returns the indexes of the lines a coupon applies to.
+func (p Promotion) matching(lines []line) []int {
+	var idx []int
+	for i, l := range lines {
+		if p.SKU == "" || p.SKU == l.SKU {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+func (p Promotion) validate() error {
+	switch {
+	case p.Code == "":
+		return fmt.Errorf("promotion: missing code")
+	case p.Kind < PercentOff || p.Kind > VolumeDiscount:
+		return fmt.Errorf("promotion %s: unknown kind %d", p.Code, p.Kind)
+	case p.Percent < 0 || p.Percent > RateScale:
+		return fmt.Errorf("promotion %s: percent %s out of range 0-1", p.Code, p.Percent)
+	case p.Amount < 0 || p.Cap < 0:
+		return fmt.Errorf("promotion %s: negative amount", p.Code)
+	case p.Kind == BuyXGetY && (p.Buy <= 0 || p.Get <= 0):
+		return fmt.Errorf("promotion %s: buy and get must be positive", p.Code)
+	case (p.Kind == BuyXGetY || p.Kind == VolumeDiscount) && p.SKU == "":
+		return fmt.Errorf("promotion %s: SKU is required", p.Code)
+	}
+	for _, t := range p.Tiers {
+		if t.Percent < 0 || t.Percent > RateScale {
+			return fmt.Errorf("promotion %s: tier percent %s out of range 0-1", p.Code, t.Percent)
+		}
+	}
+	return nil
+}
+
+func savedBy(applied []AppliedPromotion, code string) Money {
+	var total Money
+	for _, a := range applied {
+		if a.Code == code {
+			total += a.Saved
+		}
+	}
+	return total
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalculateSummary_PercentCouponBeforeVAT(t *testing.T) {
+	o := Order{
+		Currency: "RON",
+		Items: []LineItem{
+			{SKU: "TSHIRT", UnitPrice: 10000, Qty: 2},
+			{SKU: "BOOK", UnitPrice: 5000, Qty: 1, TaxCategory: TaxReduced},
+		},
+		VATRate:            RateFromFloat(0.19),
+		TaxRates:           map[TaxCategory]Rate{TaxReduced: RateFromFloat(0.05)},
+		PlatformFeePercent: RateFromFloat(0.10),
+		Promotions:         []Promotion{{Code: "TEN", Kind: PercentOff, Percent: RateFromFloat(0.10)}},
+	}
+
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Discount != 2500 || got.Subtotal != 22500 {
+		t.Errorf("expected 25.00 off a 225.00 subtotal, got %+v", got)
+	}
+	// 180.00 * 19% + 45.00 * 5%
+	if got.VAT != 3420+225 {
+		t.Errorf("expected VAT on discounted lines, got %d", got.VAT)
+	}
+	if got.PlatformFee != 2250 {
+		t.Errorf("expected platform fee on discounted subtotal, got %d", got.PlatformFee)
+	}
+	want := []AppliedPromotion{{Code: "TEN", Saved: 2500}}
+	if !reflect.DeepEqual(got.Promotions, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got.Promotions, want)
+	}
+}
+
+func TestCalculateSummary_PromotionStacking(t *testing.T) {
+	o := Order{
+		Currency: "RON",
+		Items: []LineItem{
+			{SKU: "MUG", UnitPrice: 1000, Qty: 7},
+			{SKU: "PEN", UnitPrice: 200, Qty: 10},
+		},
+		VATRate: RateFromFloat(0.19),
+		Promotions: []Promotion{
+			// line promotions on MUG: 3-for-2 saves 20.00, 20% volume saves 14.00
+			{Code: "VOL", Kind: VolumeDiscount, SKU: "MUG", Tiers: []VolumeTier{
+				{MinQty: 3, Percent: RateFromFloat(0.10)},
+				{MinQty: 5, Percent: RateFromFloat(0.20)},
+			}},
+			{Code: "3FOR2", Kind: BuyXGetY, SKU: "MUG", Buy: 2, Get: 1},
+			{Code: "PENS", Kind: VolumeDiscount, SKU: "PEN", Tiers: []VolumeTier{{MinQty: 10, Percent: RateFromFloat(0.25)}}},
+			// coupons: fixed after percent even though listed first
+			{Code: "FIVE", Kind: AmountOff, Amount: 500},
+			{Code: "HALF", Kind: PercentOff, Percent: RateFromFloat(0.50), Cap: 2000},
+		},
+	}
+
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MUG 70.00 - 20.00 = 50.00, PEN 20.00 - 5.00 = 15.00
+	// HALF: 50% of 65.00 capped at 20.00, then FIVE: 5.00
+	want := []AppliedPromotion{
+		{Code: "3FOR2", SKU: "MUG", Saved: 2000},
+		{Code: "PENS", SKU: "PEN", Saved: 500},
+		{Code: "HALF", Saved: 2000},
+		{Code: "FIVE", Saved: 500},
+	}
+	if !reflect.DeepEqual(got.Promotions, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got.Promotions, want)
+	}
+	if got.Discount != 5000 || got.Subtotal != 4000 {
+		t.Errorf("expected 50.00 off, subtotal 40.00, got %+v", got)
+	}
+}
+
+func TestCalculateSummary_MaxDiscount(t *testing.T) {
+	o := Order{
+		Currency: "EUR",
+		Items:    []LineItem{{SKU: "BAG", UnitPrice: 10000, Qty: 1}},
+		VATRate:  RateFromFloat(0.19),
+		Promotions: []Promotion{
+			{Code: "TWENTY", Kind: PercentOff, Percent: RateFromFloat(0.20)},
+			{Code: "TEN", Kind: AmountOff, Amount: 1000},
+		},
+		MaxDiscount:        2500,
+		SettlementCurrency: "EUR",
+	}
+
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AppliedPromotion{{Code: "TWENTY", Saved: 2000}, {Code: "TEN", Saved: 500}}
+	if !reflect.DeepEqual(got.Promotions, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got.Promotions, want)
+	}
+	if got.Subtotal != 7500 {
+		t.Errorf("expected subtotal 75.00, got %d", got.Subtotal)
+	}
+}
+
+func TestCalculateSummary_InvalidPromotion(t *testing.T) {
+	o := Order{
+		Currency:   "EUR",
+		Items:      []LineItem{{SKU: "BAG", UnitPrice: 10000, Qty: 1}},
+		Promotions: []Promotion{{Code: "FREE", Kind: BuyXGetY, SKU: "BAG"}},
+	}
+	if _, err := CalculateSummary(o); err == nil {
+		t.Fatal("expected an error for buy 0 get 0")
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	cases := []struct {
+		total   Money
+		weights []Money
+		want    []Money
+	}{
+		{100, []Money{1, 1, 1}, []Money{34, 33, 33}},
+		{1, []Money{1, 2}, []Money{0, 1}},
+		{10, []Money{3, 3, 4}, []Money{3, 3, 4}},
+		{-5, []Money{1, 1}, []Money{-3, -2}},
+		{7, []Money{0, 0}, []Money{7, 0}},
+		{-500, []Money{-401, -99}, []Money{-401, -99}},
+		{100, []Money{-1, 3}, []Money{25, 75}},
+	}
+	for _, c := range cases {
+		if got := allocate(c.total, c.weights); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("allocate(%d, %v) = %v, want %v", c.total, c.weights, got, c.want)
+		}
+	}
+}
+
+func TestCalculateOrderSummary_Coupon(t *testing.T) {
+	in := OrderInput{
+		Items: []Item{
+			{SKU: "TSHIRT", UnitPrice: 100.00, Qty: 2},
+			{SKU: "MUG", UnitPrice: 40.00, Qty: 1},
+		},
+		VATRate:            0.19,
+		ProcessingFee:      ProcessingFee{Percent: 0.029, Fixed: 1.20},
+		PlatformFeePercent: 0.10,
+		Currency:           "RON",
+		Promotions:         []PromotionInput{{Code: "WELCOME", Kind: AmountOff, Amount: 40}},
+	}
+
+	got := CalculateOrderSummary(in)
+	want := OrderSummary{
+		Currency:           "RON",
+		Subtotal:           200.00,
+		VAT:                38.00,
+		TotalCollected:     238.00,
+		ProcessingFee:      8.10,
+		PlatformFee:        20.00,
+		SellerPayout:       171.90,
+		PlatformRevenue:    20.00,
+		SellerPayoutRON:    171.90,
+		PlatformRevenueRON: 20.00,
+	}
+	assertEqual(t, got, want)
+	if got.Discount != 40 || len(got.Promotions) != 1 || got.Promotions[0].Saved != 40 {
+		t.Errorf("expected WELCOME to save 40.00, got %+v", got.Promotions)
+	}
+}
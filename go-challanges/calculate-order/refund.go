@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// FixedFeePolicy decides whether the processor gives back
+// ProcessingFee.Fixed when an order is refunded. The percentage part is
+// always returned in proportion.
+type FixedFeePolicy int
+
+const (
+	FixedFeeKept         FixedFeePolicy = iota // never returned
+	FixedFeeProrated                           // returned in proportion to the refund
+	FixedFeeOnFullReturn                       // returned only by the refund that empties the order
+)
+
+var ErrInvalidReturn = errors.New("invalid return")
+
+// Reversal is the money moved by one refund, positive when it flows back
+// from where CalculateSummary sent it. ProcessingFeeKept goes negative
+// when the last refund hands back a fee kept by earlier ones.
+type Reversal struct {
+	Returned map[string]int // SKU → quantity returned by this refund
+	Currency string
+
+	Subtotal          Money // net amount of the returned goods
+	VAT               Money // VAT reclaimed
+	TotalRefunded     Money // paid back to the customer, Subtotal + VAT
+	ProcessingFee     Money // returned by the payment processor
+	ProcessingFeeKept Money // the processor's share of this refund it keeps
+	PlatformFee       Money // commission the platform gives back
+	SellerClawback    Money // taken back from the seller's payout
+
+	Settlement   Settlement // SellerPayout holds the clawback
+	VATBreakdown []VATBucket
+
+	Sellers []SellerReversal // sorted by SellerID, adding up to the amounts above
+}
+
+// SellerReversal is one seller's part of a Reversal. Its PlatformFee is
+// given back at the seller's own fee percentage.
+type SellerReversal struct {
+	SellerID          string
+	Subtotal          Money
+	VAT               Money
+	TotalRefunded     Money
+	ProcessingFee     Money
+	ProcessingFeeKept Money
+	PlatformFee       Money
+	SellerClawback    Money
+	SettledClawback   Money // in the settlement currency
+	SettledRevenue    Money // the platform fee, in the settlement currency
+}
+
+// --- main logic ---
+
+// CalculateReversal prices a refund of returned (SKU → quantity) against
+// the order o that produced original. previous holds the refunds already
+// made on the order, oldest first.
+//
+// Every amount is computed for all returns so far and the previous
+// refunds are subtracted, so the refunds of an order add up exactly:
+// once everything is returned their TotalRefunded, VAT and PlatformFee sum
+// to the original, and their ProcessingFee + ProcessingFeeKept sum to the
+// original ProcessingFee.
+//
+// The amounts are split between the sellers of the returned items the way
+// CalculateSummary split the order. Each seller gets back the platform
+// fee in proportion to its own returned subtotal, so a seller charged 5%
+// is not refunded at the order's blended rate. The seller bears the
+// processing fee the processor keeps:
+// SellerClawback = TotalRefunded - VAT - PlatformFee - ProcessingFee.
+func CalculateReversal(o Order, original Summary, previous []Reversal, returned map[string]int, policy FixedFeePolicy) (Reversal, error) {
+	lines, _ := o.billableLines()
+	if _, err := applyPromotions(o, lines); err != nil {
+		return Reversal{}, err
+	}
+
+	// --- cumulative returned quantities ---
+	ordered := map[string]int{}
+	for _, l := range lines {
+		ordered[l.SKU] += l.Qty
+	}
+	cumQty := map[string]int{}
+	for _, r := range previous {
+		for sku, q := range r.Returned {
+			cumQty[sku] += q
+		}
+	}
+	var errs []error
+	for _, sku := range slices.Sorted(maps.Keys(returned)) {
+		q := returned[sku]
+		switch {
+		case q <= 0:
+			errs = append(errs, fmt.Errorf("%w: SKU %q quantity %d must be positive", ErrInvalidReturn, sku, q))
+		case ordered[sku] == 0:
+			errs = append(errs, fmt.Errorf("%w: SKU %q is not in the order", ErrInvalidReturn, sku))
+		case cumQty[sku]+q > ordered[sku]:
+			errs = append(errs, fmt.Errorf("%w: SKU %q returns %d, only %d left", ErrInvalidReturn, sku, q, ordered[sku]-cumQty[sku]))
+		}
+		cumQty[sku] += q
+	}
+	if len(errs) > 0 {
+		return Reversal{}, errors.Join(errs...)
+	}
+
+	full := true
+	for sku, q := range ordered {
+		full = full && cumQty[sku] == q
+	}
+
+	// --- cumulative amounts, prorated per VAT rate ---
+	mode := o.Rounding
+	standard := o.standardVATRate()
+	billed := map[Rate]Money{}
+	back := map[Rate]Money{}
+	backBySeller := map[Rate]map[string]Money{}
+	left := maps.Clone(cumQty)
+	for _, l := range lines {
+		r, err := o.taxRate(l.TaxCategory, standard)
+		if err != nil {
+			return Reversal{}, err
+		}
+		take := min(left[l.SKU], l.Qty)
+		left[l.SKU] -= take
+		amount := l.Amount.Prorate(Money(take), Money(l.Qty), mode)
+		billed[r] += l.Amount
+		back[r] += amount
+		if backBySeller[r] == nil {
+			backBySeller[r] = map[string]Money{}
+		}
+		backBySeller[r][l.SellerID] += amount
+	}
+
+	var cum Reversal
+	for _, sh := range original.Sellers {
+		cum.Sellers = append(cum.Sellers, SellerReversal{SellerID: sh.SellerID})
+	}
+	weights := make([]Money, len(cum.Sellers))
+	for _, b := range original.VATBreakdown {
+		bucket := VATBucket{
+			Rate: b.Rate,
+			Net:  b.Net.Prorate(back[b.Rate], billed[b.Rate], mode),
+			VAT:  b.VAT.Prorate(back[b.Rate], billed[b.Rate], mode),
+		}
+		cum.VATBreakdown = append(cum.VATBreakdown, bucket)
+		cum.Subtotal += bucket.Net
+		cum.VAT += bucket.VAT
+
+		for i, sr := range cum.Sellers {
+			weights[i] = backBySeller[b.Rate][sr.SellerID]
+		}
+		net, vat := allocate(bucket.Net, weights), allocate(bucket.VAT, weights)
+		for i := range cum.Sellers {
+			cum.Sellers[i].Subtotal += net[i]
+			cum.Sellers[i].VAT += vat[i]
+		}
+	}
+	cum.TotalRefunded = cum.Subtotal + cum.VAT
+	for i, sh := range original.Sellers {
+		sr := &cum.Sellers[i]
+		sr.TotalRefunded = sr.Subtotal + sr.VAT
+		sr.PlatformFee = sh.PlatformFee.Prorate(sr.Subtotal, sh.Subtotal, mode)
+		cum.PlatformFee += sr.PlatformFee
+	}
+
+	fixed := clampMin(o.ProcessingFee.Fixed, 0)
+	share := original.ProcessingFee.Prorate(cum.TotalRefunded, original.TotalCollected, mode)
+	switch {
+	case policy == FixedFeeProrated, policy == FixedFeeOnFullReturn && full:
+		cum.ProcessingFee = share
+	default:
+		cum.ProcessingFee = (original.ProcessingFee - fixed).Prorate(cum.TotalRefunded, original.TotalCollected, mode)
+	}
+	if full {
+		share = original.ProcessingFee
+	}
+	cum.ProcessingFeeKept = share - cum.ProcessingFee
+	cum.SellerClawback = cum.TotalRefunded - cum.VAT - cum.PlatformFee - cum.ProcessingFee
+
+	for i, sr := range cum.Sellers {
+		weights[i] = sr.TotalRefunded
+	}
+	fees, shares := allocate(cum.ProcessingFee, weights), allocate(share, weights)
+	for i := range cum.Sellers {
+		sr := &cum.Sellers[i]
+		sr.ProcessingFee = fees[i]
+		sr.ProcessingFeeKept = shares[i] - fees[i]
+		sr.SellerClawback = sr.TotalRefunded - sr.VAT - sr.PlatformFee - sr.ProcessingFee
+	}
+
+	st := original.Settlement
+	cur, err := LookupCurrency(original.Currency)
+	if err != nil {
+		return Reversal{}, err
+	}
+	settle, err := LookupCurrency(st.Currency)
+	if err != nil {
+		return Reversal{}, err
+	}
+	cum.Settlement = Settlement{
+		Currency:        st.Currency,
+		Rate:            st.Rate,
+		RateDate:        st.RateDate,
+		SellerPayout:    cum.SellerClawback.Convert(st.Rate, cur.Exponent, settle.Exponent, mode),
+		PlatformRevenue: cum.PlatformFee.Convert(st.Rate, cur.Exponent, settle.Exponent, mode),
+	}
+	clawbacks, platformFees := make([]Money, len(cum.Sellers)), make([]Money, len(cum.Sellers))
+	for i, sr := range cum.Sellers {
+		clawbacks[i], platformFees[i] = sr.SellerClawback, sr.PlatformFee
+	}
+	clawbacks = settleEach(cum.Settlement.SellerPayout, clawbacks, st.Rate, cur.Exponent, settle.Exponent, mode)
+	platformFees = settleEach(cum.Settlement.PlatformRevenue, platformFees, st.Rate, cur.Exponent, settle.Exponent, mode)
+	for i := range cum.Sellers {
+		cum.Sellers[i].SettledClawback = clawbacks[i]
+		cum.Sellers[i].SettledRevenue = platformFees[i]
+	}
+
+	// --- this refund = cumulative - previous refunds ---
+	rev := cum
+	rev.Returned = maps.Clone(returned)
+	rev.Currency = original.Currency
+	rev.VATBreakdown = slices.Clone(cum.VATBreakdown)
+	rev.Sellers = slices.Clone(cum.Sellers)
+	for _, p := range previous {
+		rev.Subtotal -= p.Subtotal
+		rev.VAT -= p.VAT
+		rev.TotalRefunded -= p.TotalRefunded
+		rev.ProcessingFee -= p.ProcessingFee
+		rev.ProcessingFeeKept -= p.ProcessingFeeKept
+		rev.PlatformFee -= p.PlatformFee
+		rev.SellerClawback -= p.SellerClawback
+		rev.Settlement.SellerPayout -= p.Settlement.SellerPayout
+		rev.Settlement.PlatformRevenue -= p.Settlement.PlatformRevenue
+		for _, pb := range p.VATBreakdown {
+			i := slices.IndexFunc(rev.VATBreakdown, func(b VATBucket) bool { return b.Rate == pb.Rate })
+			if i >= 0 {
+				rev.VATBreakdown[i].Net -= pb.Net
+				rev.VATBreakdown[i].VAT -= pb.VAT
+			}
+		}
+		for _, ps := range p.Sellers {
+			i := slices.IndexFunc(rev.Sellers, func(sr SellerReversal) bool { return sr.SellerID == ps.SellerID })
+			if i >= 0 {
+				rev.Sellers[i].sub(ps)
+			}
+		}
+	}
+	return rev, nil
+}
+
+// ReverseOrder is CalculateReversal for the float API: it recomputes the
+// original summary from in.
+func ReverseOrder(in OrderInput, previous []Reversal, returned map[string]int, policy FixedFeePolicy) (Reversal, error) {
+	o, err := in.exact()
+	if err != nil {
+		return Reversal{}, err
+	}
+	original, err := CalculateSummary(o)
+	if err != nil {
+		return Reversal{}, err
+	}
+	return CalculateReversal(o, original, previous, returned, policy)
+}
+
+// --- helpers ---
+
+func (r *SellerReversal) sub(p SellerReversal) {
+	r.Subtotal -= p.Subtotal
+	r.VAT -= p.VAT
+	r.TotalRefunded -= p.TotalRefunded
+	r.ProcessingFee -= p.ProcessingFee
+	r.ProcessingFeeKept -= p.ProcessingFeeKept
+	r.PlatformFee -= p.PlatformFee
+	r.SellerClawback -= p.SellerClawback
+	r.SettledClawback -= p.SettledClawback
+	r.SettledRevenue -= p.SettledRevenue
+}
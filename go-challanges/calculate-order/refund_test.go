@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func refundOrder() Order {
+	return Order{
+		Currency: "EUR",
+		Items: []LineItem{
+			{SKU: "TSHIRT", UnitPrice: 3333, Qty: 3},
+			{SKU: "BOOK", UnitPrice: 1999, Qty: 2, TaxCategory: TaxReduced},
+		},
+		VATRate:            RateFromFloat(0.19),
+		TaxRates:           map[TaxCategory]Rate{TaxReduced: RateFromFloat(0.09)},
+		ProcessingFee:      Fee{Percent: RateFromFloat(0.029), Fixed: 25},
+		PlatformFeePercent: RateFromFloat(0.10),
+		Promotions:         []Promotion{{Code: "SPRING", Kind: PercentOff, Percent: RateFromFloat(0.07)}},
+		Rates:              StaticRates{Rates: map[CurrencyPair]Rate{{"EUR", "RON"}: RateFromFloat(4.9731)}},
+	}
+}
+
+func TestCalculateReversal_Reconciles(t *testing.T) {
+	o := refundOrder()
+	original, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []FixedFeePolicy{FixedFeeKept, FixedFeeProrated, FixedFeeOnFullReturn} {
+		var refunds []Reversal
+		for _, returned := range []map[string]int{
+			{"TSHIRT": 1},
+			{"BOOK": 1, "TSHIRT": 1},
+			{"TSHIRT": 1, "BOOK": 1},
+		} {
+			r, err := CalculateReversal(o, original, refunds, returned, policy)
+			if err != nil {
+				t.Fatalf("policy %d: %v", policy, err)
+			}
+			if r.TotalRefunded != r.Subtotal+r.VAT {
+				t.Errorf("policy %d: refunded %d != %d + %d", policy, r.TotalRefunded, r.Subtotal, r.VAT)
+			}
+			if r.SellerClawback != r.TotalRefunded-r.VAT-r.PlatformFee-r.ProcessingFee {
+				t.Errorf("policy %d: clawback does not balance: %+v", policy, r)
+			}
+			refunds = append(refunds, r)
+		}
+
+		var sum Reversal
+		for _, r := range refunds {
+			sum.Subtotal += r.Subtotal
+			sum.VAT += r.VAT
+			sum.TotalRefunded += r.TotalRefunded
+			sum.ProcessingFee += r.ProcessingFee
+			sum.ProcessingFeeKept += r.ProcessingFeeKept
+			sum.PlatformFee += r.PlatformFee
+			sum.SellerClawback += r.SellerClawback
+			sum.Settlement.PlatformRevenue += r.Settlement.PlatformRevenue
+		}
+		if sum.Subtotal != original.Subtotal || sum.VAT != original.VAT || sum.TotalRefunded != original.TotalCollected {
+			t.Errorf("policy %d: refunds %+v do not add up to %+v", policy, sum, original)
+		}
+		if sum.PlatformFee != original.PlatformFee || sum.Settlement.PlatformRevenue != original.Settlement.PlatformRevenue {
+			t.Errorf("policy %d: platform fee refunded %d, charged %d", policy, sum.PlatformFee, original.PlatformFee)
+		}
+		if sum.ProcessingFee+sum.ProcessingFeeKept != original.ProcessingFee {
+			t.Errorf("policy %d: processing fee %d + %d != %d", policy, sum.ProcessingFee, sum.ProcessingFeeKept, original.ProcessingFee)
+		}
+
+		wantKept := Money(0)
+		if policy == FixedFeeKept {
+			wantKept = o.ProcessingFee.Fixed
+		}
+		if sum.ProcessingFeeKept != wantKept {
+			t.Errorf("policy %d: expected %d kept, got %d", policy, wantKept, sum.ProcessingFeeKept)
+		}
+		if sum.SellerClawback != original.SellerPayout+wantKept {
+			t.Errorf("policy %d: clawback %d, payout %d", policy, sum.SellerClawback, original.SellerPayout)
+		}
+	}
+}
+
+func TestCalculateReversal_FixedFeeOnFullReturn(t *testing.T) {
+	o := refundOrder()
+	original, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := CalculateReversal(o, original, nil, map[string]int{"TSHIRT": 3}, FixedFeeOnFullReturn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ProcessingFeeKept == 0 {
+		t.Errorf("expected the fixed fee to be kept on a partial return, got %+v", first)
+	}
+
+	last, err := CalculateReversal(o, original, []Reversal{first}, map[string]int{"BOOK": 2}, FixedFeeOnFullReturn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ProcessingFeeKept+last.ProcessingFeeKept != 0 {
+		t.Errorf("expected the last refund to give the kept fee back, got %d and %d", first.ProcessingFeeKept, last.ProcessingFeeKept)
+	}
+}
+
+func TestCalculateReversal_InvalidReturns(t *testing.T) {
+	o := refundOrder()
+	original, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := CalculateReversal(o, original, nil, map[string]int{"BOOK": 2}, FixedFeeKept)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, returned := range []map[string]int{
+		{"BOOK": 1},
+		{"MUG": 1},
+		{"TSHIRT": 0},
+		{"TSHIRT": 4},
+	} {
+		if _, err := CalculateReversal(o, original, []Reversal{first}, returned, FixedFeeKept); !errors.Is(err, ErrInvalidReturn) {
+			t.Errorf("%v: expected ErrInvalidReturn, got %v", returned, err)
+		}
+	}
+}
+
+func TestReverseOrder(t *testing.T) {
+	in := OrderInput{
+		Items: []Item{
+			{SKU: "TSHIRT", UnitPrice: 100.00, Qty: 2},
+			{SKU: "MUG", UnitPrice: 40.00, Qty: 1},
+		},
+		VATRate:            0.19,
+		ProcessingFee:      ProcessingFee{Percent: 0.029, Fixed: 1.20},
+		PlatformFeePercent: 0.10,
+		Currency:           "RON",
+	}
+
+	r, err := ReverseOrder(in, nil, map[string]int{"MUG": 1}, FixedFeeKept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 40.00 + 7.60 VAT; 2.9% of 47.60 = 1.38; 10% of 40.00 = 4.00
+	want := Reversal{Subtotal: 4000, VAT: 760, TotalRefunded: 4760, ProcessingFee: 138, PlatformFee: 400, SellerClawback: 3462}
+	if r.Subtotal != want.Subtotal || r.VAT != want.VAT || r.TotalRefunded != want.TotalRefunded ||
+		r.ProcessingFee != want.ProcessingFee || r.PlatformFee != want.PlatformFee || r.SellerClawback != want.SellerClawback {
+		t.Errorf("\nGot:  %+v\nWant: %+v", r, want)
+	}
+}
+
+func TestCalculateReversal_PerSellerPlatformFee(t *testing.T) {
+	o := Order{
+		Currency: "RON",
+		Items: []LineItem{
+			{SKU: "A", UnitPrice: 10000, Qty: 1, SellerID: "s-1"},
+			{SKU: "B", UnitPrice: 10000, Qty: 1, SellerID: "s-2"},
+		},
+		VATRate:            RateFromFloat(0.19),
+		SellerPlatformFees: map[string]Rate{"s-1": RateFromFloat(0.05), "s-2": RateFromFloat(0.25)},
+	}
+	original, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := CalculateReversal(o, original, nil, map[string]int{"A": 1}, FixedFeeKept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// s-1's 5% of 100.00, not the order's blended 15%
+	if r.PlatformFee != 500 || r.SellerClawback != 9500 {
+		t.Errorf("expected 5.00 platform fee and 95.00 clawback, got %d and %d", r.PlatformFee, r.SellerClawback)
+	}
+	want := []SellerReversal{
+		{SellerID: "s-1", Subtotal: 10000, VAT: 1900, TotalRefunded: 11900, PlatformFee: 500, SellerClawback: 9500,
+			SettledClawback: 9500, SettledRevenue: 500},
+		{SellerID: "s-2"},
+	}
+	if !reflect.DeepEqual(r.Sellers, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", r.Sellers, want)
+	}
+}
+
+func TestCalculateReversal_SellersReconcile(t *testing.T) {
+	o := refundOrder()
+	o.Items[0].SellerID = "s-1"
+	o.Items[1].SellerID = "s-2"
+	o.SellerPlatformFees = map[string]Rate{"s-2": RateFromFloat(0.22)}
+	original, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sums := map[string]SellerReversal{}
+	var refunds []Reversal
+	for _, returned := range []map[string]int{{"TSHIRT": 1}, {"BOOK": 1, "TSHIRT": 1}, {"TSHIRT": 1, "BOOK": 1}} {
+		r, err := CalculateReversal(o, original, refunds, returned, FixedFeeProrated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sum SellerReversal
+		for _, sr := range r.Sellers {
+			if sr.SellerClawback != sr.TotalRefunded-sr.VAT-sr.PlatformFee-sr.ProcessingFee {
+				t.Errorf("%s clawback does not balance: %+v", sr.SellerID, sr)
+			}
+			sum.Subtotal += sr.Subtotal
+			sum.PlatformFee += sr.PlatformFee
+			sum.SellerClawback += sr.SellerClawback
+			sum.SettledClawback += sr.SettledClawback
+
+			total := sums[sr.SellerID]
+			total.PlatformFee += sr.PlatformFee
+			total.SellerClawback += sr.SellerClawback
+			total.SettledClawback += sr.SettledClawback
+			sums[sr.SellerID] = total
+		}
+		if sum.Subtotal != r.Subtotal || sum.PlatformFee != r.PlatformFee || sum.SellerClawback != r.SellerClawback ||
+			sum.SettledClawback != r.Settlement.SellerPayout {
+			t.Errorf("sellers %+v do not add up to %+v", sum, r)
+		}
+		refunds = append(refunds, r)
+	}
+
+	// everything returned: each seller gets back exactly what it was charged
+	for _, sh := range original.Sellers {
+		got := sums[sh.SellerID]
+		if got.PlatformFee != sh.PlatformFee || got.SellerClawback != sh.SellerPayout || got.SettledClawback != sh.SettledPayout {
+			t.Errorf("%s: refunded %+v, charged %+v", sh.SellerID, got, sh)
+		}
+	}
+}
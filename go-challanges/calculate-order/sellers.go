@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// SellerShare is one seller's part of an order. The shares of an order add
+// up exactly to its Summary: Subtotal, VAT, ProcessingFee, PlatformFee,
+// SellerPayout and both settled amounts.
+type SellerShare struct {
+	SellerID           string // empty for items without a seller
+	PlatformFeePercent Rate
+
+	Subtotal       Money
+	Discount       Money
+	VAT            Money
+	TotalCollected Money
+	ProcessingFee  Money
+	PlatformFee    Money
+	SellerPayout   Money
+
+	SettledPayout  Money // in the settlement currency
+	SettledRevenue Money // the platform fee, in the settlement currency
+}
+
+// --- main logic ---
+
+// sellerShares splits an order between the sellers of its lines, sorted
+// by SellerID.
+//
+// Each VAT bucket is split in proportion to the sellers' amounts at that
+// rate, and the processing fee in proportion to their TotalCollected.
+// Leftover cents go to the seller with the largest amount, the lowest
+// SellerID on a tie (see allocate), so the split is deterministic.
+// The platform fee is charged per seller at its own percentage.
+func sellerShares(o Order, lines []line, breakdown []VATBucket, processingFee Money) ([]SellerShare, error) {
+	standard := o.standardVATRate()
+	ids := map[string]bool{}
+	amounts := map[Rate]map[string]Money{}
+	discount := map[string]Money{}
+	for _, l := range lines {
+		r, err := o.taxRate(l.TaxCategory, standard)
+		if err != nil {
+			return nil, fmt.Errorf("item %s: %w", l.SKU, err)
+		}
+		if amounts[r] == nil {
+			amounts[r] = map[string]Money{}
+		}
+		ids[l.SellerID] = true
+		amounts[r][l.SellerID] += l.Amount
+		discount[l.SellerID] += l.amount() - l.Amount
+	}
+
+	sellers := slices.Sorted(maps.Keys(ids))
+	shares := make([]SellerShare, len(sellers))
+	for i, id := range sellers {
+		shares[i] = SellerShare{SellerID: id, PlatformFeePercent: o.sellerFeePercent(id), Discount: discount[id]}
+	}
+
+	weights := make([]Money, len(sellers))
+	for _, b := range breakdown {
+		for i, id := range sellers {
+			weights[i] = amounts[b.Rate][id]
+		}
+		net, vat := allocate(b.Net, weights), allocate(b.VAT, weights)
+		for i := range shares {
+			shares[i].Subtotal += net[i]
+			shares[i].VAT += vat[i]
+		}
+	}
+
+	for i := range shares {
+		shares[i].TotalCollected = shares[i].Subtotal + shares[i].VAT
+		weights[i] = shares[i].TotalCollected
+	}
+	for i, fee := range allocate(processingFee, weights) {
+		sh := &shares[i]
+		sh.ProcessingFee = fee
+		sh.PlatformFee = sh.Subtotal.MulRate(sh.PlatformFeePercent, o.Rounding)
+		sh.SellerPayout = sh.TotalCollected - sh.ProcessingFee - sh.PlatformFee - sh.VAT
+	}
+	return shares, nil
+}
+
+// settleShares converts every share's payout and platform fee at st.Rate
+// so they add up to st exactly (see settleEach).
+func settleShares(shares []SellerShare, st Settlement, fromExp, toExp int, mode RoundingMode) {
+	payouts := make([]Money, len(shares))
+	fees := make([]Money, len(shares))
+	for i, sh := range shares {
+		payouts[i] = sh.SellerPayout
+		fees[i] = sh.PlatformFee
+	}
+	settled := settleEach(st.SellerPayout, payouts, st.Rate, fromExp, toExp, mode)
+	revenue := settleEach(st.PlatformRevenue, fees, st.Rate, fromExp, toExp, mode)
+	for i := range shares {
+		shares[i].SettledPayout = settled[i]
+		shares[i].SettledRevenue = revenue[i]
+	}
+}
+
+// settleEach converts every amount at rate and hands the minor units that
+// rounding each one gained or lost to the largest amounts, so they add up
+// to total, the converted sum. Converting each amount on its own keeps its
+// sign, which a split in proportion would not when one seller's payout is
+// negative.
+func settleEach(total Money, amounts []Money, rate Rate, fromExp, toExp int, mode RoundingMode) []Money {
+	out := make([]Money, len(amounts))
+	left := total
+	for i, a := range amounts {
+		out[i] = a.Convert(rate, fromExp, toExp, mode)
+		left -= out[i]
+	}
+	if len(out) > 0 {
+		spread(out, amounts, left)
+	}
+	return out
+}
+
+// sellerFeePercent is the platform fee charged to seller, clamped to 0-1.
+func (o Order) sellerFeePercent(seller string) Rate {
+	pct, ok := o.SellerPlatformFees[seller]
+	if !ok {
+		pct = o.PlatformFeePercent
+	}
+	return clamp(pct, 0, RateScale)
+}
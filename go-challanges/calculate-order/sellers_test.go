@@ -0,0 +1,197 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalculateSummary_SplitsBetweenSellers(t *testing.T) {
+	o := Order{
+		Currency: "RON",
+		Items: []LineItem{
+			{SKU: "A", UnitPrice: 10000, Qty: 1, SellerID: "s-1"},
+			{SKU: "B", UnitPrice: 5000, Qty: 1, SellerID: "s-2"},
+		},
+		VATRate:            RateFromFloat(0.19),
+		ProcessingFee:      Fee{Percent: RateFromFloat(0.029), Fixed: 30},
+		PlatformFeePercent: RateFromFloat(0.10),
+		SellerPlatformFees: map[string]Rate{"s-2": RateFromFloat(0.15)},
+	}
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []SellerShare{
+		{SellerID: "s-1", PlatformFeePercent: RateFromFloat(0.10), Subtotal: 10000, VAT: 1900, TotalCollected: 11900,
+			ProcessingFee: 366, PlatformFee: 1000, SellerPayout: 8634, SettledPayout: 8634, SettledRevenue: 1000},
+		{SellerID: "s-2", PlatformFeePercent: RateFromFloat(0.15), Subtotal: 5000, VAT: 950, TotalCollected: 5950,
+			ProcessingFee: 182, PlatformFee: 750, SellerPayout: 4068, SettledPayout: 4068, SettledRevenue: 750},
+	}
+	if !reflect.DeepEqual(got.Sellers, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got.Sellers, want)
+	}
+	if got.ProcessingFee != 548 || got.PlatformFee != 1750 || got.SellerPayout != 12702 {
+		t.Errorf("totals: %+v", got)
+	}
+}
+
+func TestCalculateSummary_SellerSharesReconcile(t *testing.T) {
+	o := Order{
+		Currency: "EUR",
+		Items: []LineItem{
+			{SKU: "A", UnitPrice: 333, Qty: 3, SellerID: "s-3"},
+			{SKU: "B", UnitPrice: 1999, Qty: 1, SellerID: "s-1", TaxCategory: TaxReduced},
+			{SKU: "C", UnitPrice: 777, Qty: 2, SellerID: "s-2"},
+			{SKU: "D", UnitPrice: 101, Qty: 7, SellerID: "s-1"},
+		},
+		VATRate:            RateFromFloat(0.19),
+		TaxRates:           map[TaxCategory]Rate{TaxReduced: RateFromFloat(0.09)},
+		ProcessingFee:      Fee{Percent: RateFromFloat(0.0315), Fixed: 25},
+		PlatformFeePercent: RateFromFloat(0.125),
+		SellerPlatformFees: map[string]Rate{"s-3": RateFromFloat(0.07)},
+		Promotions:         []Promotion{{Code: "OFF", Kind: AmountOff, Amount: 500}},
+		SettlementCurrency: "RON",
+		Rates:              StaticRates{Rates: map[CurrencyPair]Rate{{"EUR", "RON"}: RateFromFloat(4.9731)}},
+	}
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sum SellerShare
+	for _, sh := range got.Sellers {
+		if sh.SellerPayout+sh.ProcessingFee+sh.PlatformFee+sh.VAT != sh.TotalCollected {
+			t.Errorf("%s does not reconcile: %+v", sh.SellerID, sh)
+		}
+		sum.Subtotal += sh.Subtotal
+		sum.Discount += sh.Discount
+		sum.VAT += sh.VAT
+		sum.ProcessingFee += sh.ProcessingFee
+		sum.PlatformFee += sh.PlatformFee
+		sum.SellerPayout += sh.SellerPayout
+		sum.SettledPayout += sh.SettledPayout
+		sum.SettledRevenue += sh.SettledRevenue
+	}
+	want := SellerShare{
+		Subtotal: got.Subtotal, Discount: got.Discount, VAT: got.VAT, ProcessingFee: got.ProcessingFee,
+		PlatformFee: got.PlatformFee, SellerPayout: got.SellerPayout,
+		SettledPayout: got.Settlement.SellerPayout, SettledRevenue: got.Settlement.PlatformRevenue,
+	}
+	if sum != want {
+		t.Errorf("shares do not add up:\nGot:  %+v\nWant: %+v", sum, want)
+	}
+}
+
+func TestCalculateSummary_LeftoverCentGoesToLowestSellerID(t *testing.T) {
+	o := Order{
+		Currency: "RON",
+		Items: []LineItem{
+			{SKU: "B", UnitPrice: 1000, Qty: 1, SellerID: "s-b"},
+			{SKU: "A", UnitPrice: 1000, Qty: 1, SellerID: "s-a"},
+		},
+		VATRate:       RateFromFloat(0.19),
+		ProcessingFee: Fee{Fixed: 1},
+	}
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Sellers[0].SellerID != "s-a" || got.Sellers[0].ProcessingFee != 1 || got.Sellers[1].ProcessingFee != 0 {
+		t.Errorf("expected s-a to take the leftover cent, got %+v", got.Sellers)
+	}
+}
+
+func TestCalculateOrderSummary_Sellers(t *testing.T) {
+	got := CalculateOrderSummary(OrderInput{
+		Items: []Item{
+			{SKU: "A", UnitPrice: 100, Qty: 1, SellerID: "s-1"},
+			{SKU: "B", UnitPrice: 50, Qty: 1, SellerID: "s-2"},
+		},
+		VATRate:            0.19,
+		ProcessingFee:      ProcessingFee{Percent: 0.029, Fixed: 0.30},
+		PlatformFeePercent: 0.10,
+		SellerPlatformFees: map[string]float64{"s-2": 0.15},
+		Currency:           "RON",
+	})
+	if len(got.Sellers) != 2 || got.Sellers[1].SellerPayout != 40.68 || got.Sellers[1].PlatformFeePercent != 0.15 {
+		t.Errorf("unexpected sellers: %+v", got.Sellers)
+	}
+	if got.SellerPayoutRON != 127.02 {
+		t.Errorf("SellerPayoutRON: got %v, want 127.02", got.SellerPayoutRON)
+	}
+}
+
+func TestSettleShares_NegativePayouts(t *testing.T) {
+	type testCase struct {
+		name     string
+		payouts  []Money
+		rate     Rate
+		expected []Money
+	}
+
+	testCases := []testCase{
+		{name: "all negative", payouts: []Money{-401, -99}, rate: RateScale, expected: []Money{-401, -99}},
+		{name: "mixed signs", payouts: []Money{1000, -300}, rate: RateFromFloat(4.9731), expected: []Money{4973, -1492}},
+		{name: "mixed signs summing to zero", payouts: []Money{250, -250}, rate: RateFromFloat(4.9731), expected: []Money{1243, -1243}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shares := make([]SellerShare, len(tc.payouts))
+			var total Money
+			for i, p := range tc.payouts {
+				shares[i].SellerPayout = p
+				total += p
+			}
+			st := Settlement{Rate: tc.rate, SellerPayout: total.Convert(tc.rate, 2, 2, HalfUp)}
+			settleShares(shares, st, 2, 2, HalfUp)
+
+			var got []Money
+			var sum Money
+			for _, sh := range shares {
+				got = append(got, sh.SettledPayout)
+				sum += sh.SettledPayout
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+			if sum != st.SellerPayout {
+				t.Errorf("settled payouts sum to %d, want %d", sum, st.SellerPayout)
+			}
+		})
+	}
+}
+
+func TestCalculateSummary_SellerWithNegativePayout(t *testing.T) {
+	// s-1 gives its whole subtotal to the platform and still pays its
+	// share of the processing fee
+	o := Order{
+		Currency: "EUR",
+		Items: []LineItem{
+			{SKU: "A", UnitPrice: 1000, Qty: 1, SellerID: "s-1"},
+			{SKU: "B", UnitPrice: 9000, Qty: 1, SellerID: "s-2"},
+		},
+		VATRate:            RateFromFloat(0.19),
+		ProcessingFee:      Fee{Percent: RateFromFloat(0.029), Fixed: 30},
+		PlatformFeePercent: RateFromFloat(0.10),
+		SellerPlatformFees: map[string]Rate{"s-1": RateScale},
+		SettlementCurrency: "RON",
+		Rates:              StaticRates{Rates: map[CurrencyPair]Rate{{"EUR", "RON"}: RateFromFloat(4.9731)}},
+	}
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s1 := got.Sellers[0]
+	if s1.SellerPayout != -s1.ProcessingFee || s1.SellerPayout >= 0 {
+		t.Fatalf("expected s-1 to owe its processing fee, got %+v", s1)
+	}
+	if want := s1.SellerPayout.Convert(RateFromFloat(4.9731), 2, 2, HalfUp); s1.SettledPayout < want-1 || s1.SettledPayout > want+1 {
+		t.Errorf("s-1 settled %d, expected about %d", s1.SettledPayout, want)
+	}
+	if sum := s1.SettledPayout + got.Sellers[1].SettledPayout; sum != got.Settlement.SellerPayout {
+		t.Errorf("settled payouts sum to %d, want %d", sum, got.Settlement.SellerPayout)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// TaxCategory selects the VAT rate an item is charged at. The empty
+// category means TaxStandard.
+type TaxCategory string
+
+const (
+	TaxStandard     TaxCategory = "standard"
+	TaxReduced      TaxCategory = "reduced"
+	TaxSuperReduced TaxCategory = "super-reduced"
+	TaxZero         TaxCategory = "zero"
+)
+
+// TaxRounding chooses where VAT is rounded.
+type TaxRounding int
+
+const (
+	RoundPerInvoice TaxRounding = iota // once per rate, on the summed lines
+	RoundPerLine                       // on every line, then summed
+)
+
+var (
+	ErrUnknownTaxCategory = errors.New("unknown tax category")
+	// ErrNoTaxRate is a reduced category without an Order.TaxRates entry;
+	// reduced rates differ by country, so they have no default.
+	ErrNoTaxRate = errors.New("no tax rate for category")
+)
+
+// VATBucket is the VAT charged at one rate.
+type VATBucket struct {
+	Rate Rate
+	Net  Money
+	VAT  Money
+}
+
+// --- main logic ---
+
+// vatBreakdown prices the lines of o per VAT rate, sorted by rate.
+// standard is the rate used for TaxStandard when o.TaxRates has none.
+func vatBreakdown(o Order, lines []line, standard Rate) ([]VATBucket, error) {
+	var (
+		buckets []VATBucket
+		gross   = map[Rate]Money{}
+	)
+	bucket := func(r Rate) *VATBucket {
+		i := slices.IndexFunc(buckets, func(b VATBucket) bool { return b.Rate == r })
+		if i < 0 {
+			buckets = append(buckets, VATBucket{Rate: r})
+			i = len(buckets) - 1
+		}
+		return &buckets[i]
+	}
+
+	for _, l := range lines {
+		r, err := o.taxRate(l.TaxCategory, standard)
+		if err != nil {
+			return nil, fmt.Errorf("item %s: %w", l.SKU, err)
+		}
+		b := bucket(r)
+		if o.TaxRounding == RoundPerLine {
+			net, vat := splitVAT(l.Amount, r, o.PricesIncludeTax, o.Rounding)
+			b.Net += net
+			b.VAT += vat
+		} else {
+			gross[r] += l.Amount
+		}
+	}
+
+	if o.TaxRounding == RoundPerInvoice {
+		for i := range buckets {
+			b := &buckets[i]
+			b.Net, b.VAT = splitVAT(gross[b.Rate], b.Rate, o.PricesIncludeTax, o.Rounding)
+		}
+	}
+
+	slices.SortFunc(buckets, func(a, b VATBucket) int { return cmp.Compare(a.Rate, b.Rate) })
+	return buckets, nil
+}
+
+// splitVAT returns the net amount and VAT of amount at rate r. When
+// inclusive is set, amount already contains the VAT.
+func splitVAT(amount Money, r Rate, inclusive bool, mode RoundingMode) (net, vat Money) {
+	if inclusive {
+		vat = amount.MulFrac(r, RateScale+r, mode)
+		return amount - vat, vat
+	}
+	return amount, amount.MulRate(r, mode)
+}
+
+func (o Order) taxRate(c TaxCategory, standard Rate) (Rate, error) {
+	if c == "" {
+		c = TaxStandard
+	}
+	if r, ok := o.TaxRates[c]; ok {
+		return r, nil
+	}
+	switch c {
+	case TaxStandard:
+		return standard, nil
+	case TaxZero:
+		return 0, nil
+	case TaxReduced, TaxSuperReduced:
+		return 0, fmt.Errorf("%w %q", ErrNoTaxRate, c)
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownTaxCategory, c)
+}
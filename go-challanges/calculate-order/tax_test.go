@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCalculateSummary_MixedBasket(t *testing.T) {
+	o := Order{
+		Currency: "RON",
+		Items: []LineItem{
+			{SKU: "TSHIRT", UnitPrice: 10000, Qty: 2},
+			{SKU: "BOOK", UnitPrice: 5000, Qty: 1, TaxCategory: TaxReduced},
+			{SKU: "BREAD", UnitPrice: 1000, Qty: 1, TaxCategory: TaxZero},
+		},
+		VATRate:  RateFromFloat(0.19),
+		TaxRates: map[TaxCategory]Rate{TaxReduced: RateFromFloat(0.05)},
+	}
+
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VATBucket{
+		{Rate: 0, Net: 1000, VAT: 0},
+		{Rate: RateFromFloat(0.05), Net: 5000, VAT: 250},
+		{Rate: RateFromFloat(0.19), Net: 20000, VAT: 3800},
+	}
+	if !reflect.DeepEqual(got.VATBreakdown, want) {
+		t.Errorf("breakdown:\nGot:  %+v\nWant: %+v", got.VATBreakdown, want)
+	}
+	if got.Subtotal != 26000 || got.VAT != 4050 || got.TotalCollected != 30050 {
+		t.Errorf("unexpected totals %+v", got)
+	}
+}
+
+func TestCalculateSummary_TaxInclusive(t *testing.T) {
+	o := Order{
+		Currency:         "RON",
+		Items:            []LineItem{{SKU: "SOAP", UnitPrice: 119, Qty: 3}},
+		VATRate:          RateFromFloat(0.19),
+		PricesIncludeTax: true,
+	}
+
+	got, err := CalculateSummary(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Subtotal != 300 || got.VAT != 57 || got.TotalCollected != 357 {
+		t.Errorf("expected 3.00 + 0.57 = 3.57, got %+v", got)
+	}
+}
+
+func TestCalculateSummary_TaxRounding(t *testing.T) {
+	cases := []struct {
+		name      string
+		lines     int
+		price     Money
+		inclusive bool
+		perInv    Money
+		perLine   Money
+	}{
+		// 3 × 0.13 net: 0.0741 → 0.07 per invoice, 3 × 0.0247 → 0.06 per line
+		{"exclusive", 3, 13, false, 7, 6},
+		// 2 × 10.00 gross: 3.1933 → 3.19 per invoice, 2 × 1.5966 → 3.20 per line
+		{"inclusive", 2, 1000, true, 319, 320},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := Order{
+				Currency:         "RON",
+				VATRate:          RateFromFloat(0.19),
+				PricesIncludeTax: c.inclusive,
+			}
+			for i := range c.lines {
+				o.Items = append(o.Items, LineItem{SKU: string(rune('A' + i)), UnitPrice: c.price, Qty: 1})
+			}
+
+			for rounding, want := range map[TaxRounding]Money{RoundPerInvoice: c.perInv, RoundPerLine: c.perLine} {
+				o.TaxRounding = rounding
+				got, err := CalculateSummary(o)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.VAT != want {
+					t.Errorf("rounding %d: expected VAT %d, got %d", rounding, want, got.VAT)
+				}
+				if got.Subtotal+got.VAT != got.TotalCollected {
+					t.Errorf("rounding %d: subtotal + VAT != total: %+v", rounding, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateSummary_UnknownTaxCategory(t *testing.T) {
+	o := Order{
+		Currency: "RON",
+		Items:    []LineItem{{SKU: "WINE", UnitPrice: 100, Qty: 1, TaxCategory: "luxury"}},
+	}
+	if _, err := CalculateSummary(o); !errors.Is(err, ErrUnknownTaxCategory) {
+		t.Fatalf("expected ErrUnknownTaxCategory, got %v", err)
+	}
+
+	for _, c := range []TaxCategory{TaxReduced, TaxSuperReduced} {
+		o.Items[0].TaxCategory = c
+		_, err := CalculateSummary(o)
+		if !errors.Is(err, ErrNoTaxRate) || errors.Is(err, ErrUnknownTaxCategory) {
+			t.Errorf("%s: expected ErrNoTaxRate, got %v", c, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), string(c)) {
+			t.Errorf("%s: expected the error to name the category, got %v", c, err)
+		}
+	}
+}
+
+func TestCalculateOrderSummary_VATBreakdown(t *testing.T) {
+	in := OrderInput{
+		Currency: "EUR",
+		Items: []Item{
+			{SKU: "TSHIRT", UnitPrice: 50, Qty: 2},
+			{SKU: "BOOK", UnitPrice: 10, Qty: 1, TaxCategory: TaxReduced},
+		},
+		VATRate:       0.19,
+		TaxRates:      map[TaxCategory]float64{TaxReduced: 0.09},
+		ExchangeRates: map[string]float64{"EUR": 4.97},
+	}
+
+	got := CalculateOrderSummary(in)
+	want := []VATLine{
+		{Rate: 0.09, Net: 10, VAT: 0.9},
+		{Rate: 0.19, Net: 100, VAT: 19},
+	}
+	if !reflect.DeepEqual(got.VATBreakdown, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got.VATBreakdown, want)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Validation selects what CalculateSummary does with bad input.
+type Validation int
+
+const (
+	Lenient Validation = iota // skip bad items, clamp fees, default VAT to 19%
+	Strict                    // reject the order with joined *FieldError values
+)
+
+var (
+	ErrNotPositive     = errors.New("must be positive")
+	ErrNegative        = errors.New("must not be negative")
+	ErrOutOfRange      = errors.New("must be between 0 and 1")
+	ErrRequired        = errors.New("is required")
+	ErrNoBillableItems = errors.New("order has no billable items")
+)
+
+// FieldError names one invalid field. Index and SKU identify the item for
+// item fields; Index is -1 for order-level fields.
+type FieldError struct {
+	Index int
+	SKU   string
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s: %v", e.Field, e.Err)
+	}
+	return fmt.Sprintf("item %d (SKU %q) %s: %v", e.Index, e.SKU, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors flattens an error returned in Strict mode into its field errors.
+func FieldErrors(err error) []*FieldError {
+	var out []*FieldError
+	var walk func(error)
+	walk = func(err error) {
+		if fe, ok := err.(*FieldError); ok {
+			out = append(out, fe)
+			return
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				walk(e)
+			}
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		}
+	}
+	if err != nil {
+		walk(err)
+	}
+	return out
+}
+
+// --- main logic ---
+
+// validate reports every problem Lenient mode would paper over.
+func (o Order) validate() error {
+	var errs []error
+	order := func(field string, err error) {
+		errs = append(errs, &FieldError{Index: -1, Field: field, Err: err})
+	}
+	rate := func(field string, r Rate) {
+		if r < 0 || r > RateScale {
+			order(field, ErrOutOfRange)
+		}
+	}
+
+	if _, err := LookupCurrency(o.Currency); err != nil {
+		order("Currency", err)
+	}
+	if o.SettlementCurrency != "" {
+		if _, err := LookupCurrency(o.SettlementCurrency); err != nil {
+			order("SettlementCurrency", err)
+		}
+	}
+	if len(o.Items) == 0 {
+		order("Items", ErrNoBillableItems)
+	}
+	for i, item := range o.Items {
+		field := func(name string, err error) {
+			errs = append(errs, &FieldError{Index: i, SKU: item.SKU, Field: name, Err: err})
+		}
+		if item.SKU == "" {
+			field("SKU", ErrRequired)
+		}
+		if item.Qty <= 0 {
+			field("Qty", ErrNotPositive)
+		}
+		if item.UnitPrice < 0 {
+			field("UnitPrice", ErrNegative)
+		}
+	}
+
+	if _, ok := o.TaxRates[TaxStandard]; !ok {
+		if o.VATRate <= 0 {
+			order("VATRate", ErrNotPositive)
+		} else {
+			rate("VATRate", o.VATRate)
+		}
+	}
+	for _, c := range slices.Sorted(maps.Keys(o.TaxRates)) {
+		rate(fmt.Sprintf("TaxRates[%s]", c), o.TaxRates[c])
+	}
+	rate("ProcessingFee.Percent", o.ProcessingFee.Percent)
+	if o.ProcessingFee.Fixed < 0 {
+		order("ProcessingFee.Fixed", ErrNegative)
+	}
+	rate("PlatformFeePercent", o.PlatformFeePercent)
+	for _, id := range slices.Sorted(maps.Keys(o.SellerPlatformFees)) {
+		rate(fmt.Sprintf("SellerPlatformFees[%s]", id), o.SellerPlatformFees[id])
+	}
+
+	return errors.Join(errs...)
+}
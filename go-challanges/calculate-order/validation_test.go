@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCalculateOrderSummaryE_Strict(t *testing.T) {
+	in := OrderInput{
+		Items: []Item{
+			{SKU: "TSHIRT", UnitPrice: 100.00, Qty: 2},
+			{SKU: "BROKEN", UnitPrice: -5.00, Qty: 3},
+			{SKU: "MUG", UnitPrice: 40.00, Qty: 0},
+		},
+		VATRate:            -1,
+		ProcessingFee:      ProcessingFee{Percent: 0.029, Fixed: -5.0},
+		PlatformFeePercent: 1.2,
+		Currency:           "RON",
+		Validation:         Strict,
+	}
+
+	got, err := CalculateOrderSummaryE(in)
+	if err == nil {
+		t.Fatalf("expected an error, got %+v", got)
+	}
+
+	want := []FieldError{
+		{Index: 1, SKU: "BROKEN", Field: "UnitPrice", Err: ErrNegative},
+		{Index: 2, SKU: "MUG", Field: "Qty", Err: ErrNotPositive},
+		{Index: -1, Field: "VATRate", Err: ErrNotPositive},
+		{Index: -1, Field: "ProcessingFee.Fixed", Err: ErrNegative},
+		{Index: -1, Field: "PlatformFeePercent", Err: ErrOutOfRange},
+	}
+	fieldErrs := FieldErrors(err)
+	if len(fieldErrs) != len(want) {
+		t.Fatalf("expected %d field errors, got %d:\n%v", len(want), len(fieldErrs), err)
+	}
+	for i, fe := range fieldErrs {
+		if *fe != want[i] {
+			t.Errorf("field error %d: got %+v, want %+v", i, *fe, want[i])
+		}
+	}
+
+	if !errors.Is(err, ErrNegative) {
+		t.Error("expected errors.Is(err, ErrNegative)")
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.SKU != "BROKEN" {
+		t.Errorf("expected errors.As to find the BROKEN item, got %+v", fe)
+	}
+}
+
+func TestCalculateOrderSummaryE_StrictNoBillableItems(t *testing.T) {
+	in := OrderInput{
+		Items:      []Item{{SKU: "FREEBIE", UnitPrice: 0, Qty: 1}},
+		VATRate:    0.19,
+		Currency:   "RON",
+		Validation: Strict,
+	}
+	if _, err := CalculateOrderSummaryE(in); !errors.Is(err, ErrNoBillableItems) {
+		t.Fatalf("expected ErrNoBillableItems, got %v", err)
+	}
+}
+
+func TestCalculateOrderSummaryE_StrictUnknownCurrency(t *testing.T) {
+	in := OrderInput{
+		Items:      []Item{{SKU: "BOOK", UnitPrice: 20, Qty: 1}},
+		VATRate:    0.19,
+		Currency:   "XXY",
+		Validation: Strict,
+	}
+	_, err := CalculateOrderSummaryE(in)
+	if !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("expected ErrUnknownCurrency, got %v", err)
+	}
+	if fe := FieldErrors(err); len(fe) != 1 || fe[0].Field != "Currency" {
+		t.Fatalf("expected a Currency field error, got %v", fe)
+	}
+}
+
+func TestCalculateOrderSummaryE_LenientMatchesLegacy(t *testing.T) {
+	in := OrderInput{
+		Items: []Item{
+			{SKU: "GOOD", UnitPrice: 50, Qty: 1},
+			{SKU: "BAD", UnitPrice: -1, Qty: 1},
+		},
+		VATRate:            -0.05,
+		ProcessingFee:      ProcessingFee{Percent: -0.5, Fixed: -2.0},
+		PlatformFeePercent: -0.2,
+		Currency:           "RON",
+	}
+
+	got, err := CalculateOrderSummaryE(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got, CalculateOrderSummary(in))
+	if got.Subtotal != 50 || got.VAT != 9.5 {
+		t.Errorf("expected lenient defaults, got %+v", got)
+	}
+}
+
+func TestCalculateOrderSummaryE_NotFinite(t *testing.T) {
+	in := OrderInput{
+		Items: []Item{
+			{SKU: "GOOD", UnitPrice: 50, Qty: 1},
+			{SKU: "NAN", UnitPrice: math.NaN(), Qty: 1},
+		},
+		VATRate:            math.Inf(1),
+		ProcessingFee:      ProcessingFee{Fixed: 1e300},
+		PlatformFeePercent: 0.10,
+		Currency:           "RON",
+	}
+
+	// Lenient too: there is no value to clamp a NaN to
+	got, err := CalculateOrderSummaryE(in)
+	if !errors.Is(err, ErrNotFinite) {
+		t.Fatalf("expected ErrNotFinite, got %v (%+v)", err, got)
+	}
+	want := []FieldError{
+		{Index: -1, Field: "VATRate", Err: ErrNotFinite},
+		{Index: -1, Field: "ProcessingFee.Fixed", Err: ErrNotFinite},
+		{Index: 1, SKU: "NAN", Field: "UnitPrice", Err: ErrNotFinite},
+	}
+	fieldErrs := FieldErrors(err)
+	if len(fieldErrs) != len(want) {
+		t.Fatalf("expected %d field errors, got %d:\n%v", len(want), len(fieldErrs), err)
+	}
+	for i, fe := range fieldErrs {
+		if *fe != want[i] {
+			t.Errorf("field error %d: got %+v, want %+v", i, *fe, want[i])
+		}
+	}
+	assertEqual(t, got, OrderSummary{})
+}
+
+func TestCalculateOrderSummary_ErrorsGiveEmptySummary(t *testing.T) {
+	valid := func() OrderInput {
+		return OrderInput{
+			Items:              []Item{{SKU: "BOOK", UnitPrice: 20, Qty: 1}},
+			VATRate:            0.19,
+			PlatformFeePercent: 0.10,
+			Currency:           "RON",
+		}
+	}
+	type testCase struct {
+		name        string
+		modify      func(in *OrderInput)
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			name:        "unknown currency in lenient mode",
+			modify:      func(in *OrderInput) { in.Currency = "XXY" },
+			expectedErr: ErrUnknownCurrency,
+		},
+		{
+			name:        "missing exchange rate",
+			modify:      func(in *OrderInput) { in.Currency = "USD"; in.ExchangeRates = map[string]float64{"EUR": 4.97} },
+			expectedErr: ErrRateNotFound,
+		},
+		{
+			name:        "NaN price",
+			modify:      func(in *OrderInput) { in.Items[0].UnitPrice = math.NaN() },
+			expectedErr: ErrNotFinite,
+		},
+		{
+			name:        "strict field error",
+			modify:      func(in *OrderInput) { in.Validation = Strict; in.Items[0].Qty = 0 },
+			expectedErr: ErrNotPositive,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			in := valid()
+			tc.modify(&in)
+			if _, err := CalculateOrderSummaryE(in); !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v from CalculateOrderSummaryE, got %v", tc.expectedErr, err)
+			}
+			if got := CalculateOrderSummary(in); !reflect.DeepEqual(got, OrderSummary{}) {
+				t.Errorf("expected an empty summary, got %+v", got)
+			}
+		})
+	}
+
+	if got := CalculateOrderSummary(valid()); got.TotalCollected == 0 {
+		t.Errorf("expected the valid order to be priced, got %+v", got)
+	}
+}
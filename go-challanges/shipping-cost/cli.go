@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prints the quote for an order against the rate cards in effect on -date:
+//
+//	shipping-cost -rates 2024-01.json,2024-05.yaml -order order.json -format table
+//
+// With -report, the per-currency totals of cards that declare currencies
+// are also converted with the -fx rates:
+//
+//	shipping-cost -rates 2024-09.csv -order order.json -report RON -fx EUR=4.97,USD=4.58
+//
+// With -serve, it runs the quote service instead, reloading the rate
+// cards when their files change (see Server):
+//
+//	shipping-cost -rates 2024-01.json,2024-09.csv -serve :8080
+//
+// The order uses the shape of the challenge description, one object or an
+// array of them:
+//
+//	{"country": "US", "items": [{"name": "mouse", "quantity": 20}]}
+func main() {
+	var (
+		ratesFlag = flag.String("rates", "", "comma-separated rate card files (.json, .yaml, .csv)")
+		orderPath = flag.String("order", "", "order file (default stdin)")
+		dateFlag  = flag.String("date", "", "order date, YYYY-MM-DD (default today)")
+		format    = flag.String("format", "table", "output format: table or json")
+		report    = flag.String("report", "", "reporting currency for the totals")
+		fxFlag    = flag.String("fx", "", "comma-separated CUR=rate, the price of one CUR in the reporting currency")
+		addr      = flag.String("serve", "", "serve POST /quote on this address instead")
+		reload    = flag.Duration("reload", 5*time.Second, "with -serve, how often to check the rate card files")
+	)
+	flag.Parse()
+
+	if *ratesFlag == "" {
+		log.Fatal("-rates is required")
+	}
+	if *addr != "" {
+		log.Fatal(serve(*addr, strings.Split(*ratesFlag, ","), *reload))
+	}
+	cards, err := LoadRateCards(strings.Split(*ratesFlag, ",")...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	date := time.Now()
+	if *dateFlag != "" {
+		if date, err = time.Parse(dateLayout, *dateFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	in := io.Reader(os.Stdin)
+	if *orderPath != "" {
+		f, err := os.Open(*orderPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	order, err := ReadOrderJSON(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	q, err := QuoteOrderOn(order, cards, date)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *report != "" {
+		if q.Totals == nil {
+			log.Fatalf("rate card %s declares no currencies", q.RateCard)
+		}
+		rates, err := parseRates(*fxFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := q.Totals.Report(*report, rates); err != nil {
+			log.Fatal(err)
+		}
+	}
+	switch *format {
+	case "table":
+		err = q.WriteTable(os.Stdout)
+	case "json":
+		err = q.WriteJSON(os.Stdout)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type orderRecord struct {
+	Country Country `json:"country"`
+	Items   []struct {
+		Name     Name     `json:"name"`
+		Quantity Quantity `json:"quantity"`
+	} `json:"items"`
+}
+
+// ReadOrderJSON reads one order object or an array of them, one per
+// country, into an Order. Quantities of a repeated item add up.
+func ReadOrderJSON(r io.Reader) (Order, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var records []orderRecord
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &records)
+	} else {
+		records = make([]orderRecord, 1)
+		err = json.Unmarshal(data, &records[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order := Order{}
+	for i, rec := range records {
+		if rec.Country == "" {
+			return nil, fmt.Errorf("order %d: missing country", i)
+		}
+		if order[rec.Country] == nil {
+			order[rec.Country] = Items{}
+		}
+		for _, it := range rec.Items {
+			if it.Name == "" || it.Quantity < 0 {
+				return nil, fmt.Errorf("order %d: invalid item %q quantity %d", i, it.Name, it.Quantity)
+			}
+			order[rec.Country][it.Name] += it.Quantity
+		}
+	}
+	return order, nil
+}
+
+// parseRates reads "EUR=4.97,USD=4.58".
+func parseRates(s string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for pair := range strings.SplitSeq(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("-fx: %q is not CUR=rate", pair)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("-fx: %s: %w", code, err)
+		}
+		rates[strings.ToUpper(code)] = rate
+	}
+	return rates, nil
+}
@@ -27,7 +27,18 @@ func CalculateTotal(order Order, rules OrderRules) int {
 }
 
 func calculateCostPerItem(rules []ItemRule, quantity Quantity) int {
-	q, total := int(quantity), 0
+	total := 0
+	for _, c := range tierCharges(rules, quantity) {
+		total += c.Cost
+	}
+	return total
+}
+
+// tierCharges fills rules progressively with quantity and returns what
+// each tier charged.
+func tierCharges(rules []ItemRule, quantity Quantity) []TierCharge {
+	q := int(quantity)
+	var charges []TierCharge
 	for _, r := range rules {
 		if q <= 0 {
 			break
@@ -36,12 +47,21 @@ func calculateCostPerItem(rules []ItemRule, quantity Quantity) int {
 		if low == 0 {
 			low = 1
 		}
+		if low > q {
+			break // quantity ends before this tier
+		}
 		high := r.maxQuantity
 		if high == -1 || high > q {
 			high = q
 		}
-		total += (high - low + 1) * r.cost
-
+		units := high - low + 1
+		charges = append(charges, TierCharge{
+			MinQuantity: r.minQuantity,
+			MaxQuantity: r.maxQuantity,
+			Quantity:    units,
+			UnitCost:    r.cost,
+			Cost:        units * r.cost,
+		})
 	}
-	return total
+	return charges
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ShippingPolicy holds the commercial rules of one country, applied to the
+// whole order after every item is priced.
+type ShippingPolicy struct {
+	FreeOver int      // goods value from which shipping is free, 0 disables
+	Cap      int      // most shipping to the country can cost, 0 disables
+	Bundles  []Bundle // an item takes part in the first bundle listing it
+}
+
+type ShippingPolicies map[Country]ShippingPolicy
+
+// Bundle lists items that ship together. When more than one of them is
+// ordered, the one costing most pays in full and each other one pays
+// Percent of its cost.
+type Bundle struct {
+	Name    string
+	Items   []Name
+	Percent int
+}
+
+// Adjustment is one change the order-level rules made to a quote. Amount
+// is negative for a reduction.
+type Adjustment struct {
+	Country Country `json:"country"`
+	Rule    string  `json:"rule"`
+	Items   []Name  `json:"items,omitempty"`
+	Amount  int     `json:"amount"`
+}
+
+// --- main logic ---
+
+// CalculateTotalWithPolicies is CalculateTotal followed by ApplyPolicies.
+func CalculateTotalWithPolicies(order Order, rules OrderRules, policies ShippingPolicies, goodsValue map[Country]int) int {
+	return applyRules(QuoteOrder(order, rules), policies, goodsValue).Total
+}
+
+// ApplyPolicies adjusts q with the policy of each country: bundles first,
+// then the cap, then free shipping. goodsValue is the value of the goods
+// shipped to each country, in the unit of FreeOver. Totals, if q has
+// them, are computed again from the adjusted subtotals, and so is their
+// Reporting, with the rates it was converted with.
+func ApplyPolicies(q Quote, policies ShippingPolicies, goodsValue map[Country]int) (Quote, error) {
+	q = applyRules(q, policies, goodsValue)
+	if q.Totals != nil {
+		t, err := TotalsFor(q, q.Totals.settings())
+		if err != nil {
+			return Quote{}, err
+		}
+		if r := q.Totals.Reporting; r != nil {
+			if err := t.Report(r.Currency, q.Totals.rates); err != nil {
+				return Quote{}, err
+			}
+		}
+		q.Totals = &t
+	}
+	return q, nil
+}
+
+// --- helpers ---
+
+// applyRules adjusts the lines and subtotals of q, leaving its Totals as
+// they were.
+func applyRules(q Quote, policies ShippingPolicies, goodsValue map[Country]int) Quote {
+	q.Subtotals = maps.Clone(q.Subtotals)
+	q.Adjustments = slices.Clone(q.Adjustments)
+	adjust := func(a Adjustment) {
+		if a.Amount == 0 {
+			return
+		}
+		q.Adjustments = append(q.Adjustments, a)
+		q.Subtotals[a.Country] += a.Amount
+		q.Total += a.Amount
+	}
+
+	for _, country := range slices.Sorted(maps.Keys(q.Subtotals)) {
+		p, ok := policies[country]
+		if !ok {
+			continue
+		}
+
+		bundled := map[Name]bool{}
+		for _, b := range p.Bundles {
+			var lines []QuoteLine
+			for _, l := range q.Lines {
+				if l.Country == country && l.Subtotal > 0 && slices.Contains(b.Items, l.Item) && !bundled[l.Item] {
+					lines = append(lines, l)
+				}
+			}
+			if len(lines) < 2 {
+				continue
+			}
+			slices.SortStableFunc(lines, func(a, b QuoteLine) int { return cmp.Compare(b.Subtotal, a.Subtotal) })
+
+			a := Adjustment{Country: country, Rule: "bundle " + b.Name}
+			for i, l := range lines {
+				bundled[l.Item] = true
+				a.Items = append(a.Items, l.Item)
+				if i > 0 {
+					charged := (l.Subtotal*min(max(b.Percent, 0), 100) + 50) / 100
+					a.Amount -= l.Subtotal - charged
+				}
+			}
+			adjust(a)
+		}
+
+		if p.Cap > 0 && q.Subtotals[country] > p.Cap {
+			adjust(Adjustment{Country: country, Rule: fmt.Sprintf("capped at %d", p.Cap), Amount: p.Cap - q.Subtotals[country]})
+		}
+		if p.FreeOver > 0 && goodsValue[country] >= p.FreeOver {
+			adjust(Adjustment{Country: country, Rule: fmt.Sprintf("free over %d", p.FreeOver), Amount: -q.Subtotals[country]})
+		}
+	}
+	return q
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyPolicies(t *testing.T) {
+	rules := OrderRules{
+		"US": {
+			"laptop": []ItemRule{{0, -1, 1000}},
+			"mouse":  []ItemRule{{0, -1, 300}},
+			"bag":    []ItemRule{{0, -1, 200}},
+			"pen":    []ItemRule{{0, -1, 25}},
+		},
+		"DE": {
+			"laptop": []ItemRule{{0, -1, 1200}},
+		},
+		"FR": {
+			"laptop": []ItemRule{{0, -1, 1100}},
+		},
+	}
+	policies := ShippingPolicies{
+		"US": {Bundles: []Bundle{
+			{Name: "office", Items: []Name{"laptop", "mouse", "bag"}, Percent: 50},
+			{Name: "desk", Items: []Name{"mouse", "pen"}, Percent: 0},
+		}},
+		"DE": {Cap: 2000},
+		"FR": {FreeOver: 10000, Cap: 1500},
+	}
+	order := Order{
+		"US": {"laptop": 1, "mouse": 1, "bag": 2, "pen": 4},
+		"DE": {"laptop": 3},
+		"FR": {"laptop": 2},
+	}
+	values := map[Country]int{"FR": 12000, "DE": 50000}
+
+	q, err := ApplyPolicies(QuoteOrder(order, rules), policies, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Adjustment{
+		{Country: "DE", Rule: "capped at 2000", Amount: -1600},
+		{Country: "FR", Rule: "capped at 1500", Amount: -700},
+		{Country: "FR", Rule: "free over 10000", Amount: -1500},
+		// bag (400) and mouse (300) pay half behind the laptop; pen has no partner left
+		{Country: "US", Rule: "bundle office", Items: []Name{"laptop", "bag", "mouse"}, Amount: -350},
+	}
+	if !reflect.DeepEqual(q.Adjustments, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", q.Adjustments, want)
+	}
+	wantSub := map[Country]int{"DE": 2000, "FR": 0, "US": 1000 + 300 + 400 + 100 - 350}
+	if !reflect.DeepEqual(q.Subtotals, wantSub) || q.Total != 2000+1450 {
+		t.Errorf("subtotals %v total %d", q.Subtotals, q.Total)
+	}
+	if got := CalculateTotalWithPolicies(order, rules, policies, values); got != q.Total {
+		t.Errorf("CalculateTotalWithPolicies = %d, want %d", got, q.Total)
+	}
+}
+
+func TestApplyPolicies_NoPolicyKeepsTotal(t *testing.T) {
+	rules := OrderRules{"US": {"mouse": []ItemRule{{0, -1, 550}}}}
+	order := Order{"US": {"mouse": 20}}
+
+	q, err := ApplyPolicies(QuoteOrder(order, rules), ShippingPolicies{"DE": {Cap: 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Total != CalculateTotal(order, rules) || len(q.Adjustments) != 0 {
+		t.Errorf("expected an unchanged quote, got %+v", q)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// PricingStrategy prices everything an order ships to one country.
+type PricingStrategy interface {
+	Price(country Country, items Items) (CountryQuote, error)
+}
+
+// CountryQuote is what a strategy charges for one country. Lines holds
+// per-item charges (quantity tiers), Shipment a per-parcel charge (weight
+// bands); Subtotal is the sum of both.
+type CountryQuote struct {
+	Lines    []QuoteLine
+	Shipment *ShipmentQuote
+	Unpriced []UnpricedItem
+	Subtotal int
+}
+
+// Strategies picks the strategy for each country, Default for the rest.
+type Strategies struct {
+	ByCountry map[Country]PricingStrategy
+	Default   PricingStrategy
+}
+
+func (s Strategies) For(country Country) (PricingStrategy, error) {
+	if p, ok := s.ByCountry[country]; ok {
+		return p, nil
+	}
+	if s.Default == nil {
+		return nil, fmt.Errorf("%s: no pricing strategy", country)
+	}
+	return s.Default, nil
+}
+
+// --- main logic ---
+
+// QuoteWith prices every country of order with its strategy.
+func QuoteWith(order Order, strategies Strategies) (Quote, error) {
+	q := Quote{Subtotals: map[Country]int{}}
+	for _, country := range slices.Sorted(maps.Keys(order)) {
+		p, err := strategies.For(country)
+		if err != nil {
+			return Quote{}, err
+		}
+		cq, err := p.Price(country, order[country])
+		if err != nil {
+			return Quote{}, fmt.Errorf("%s: %w", country, err)
+		}
+		q.Lines = append(q.Lines, cq.Lines...)
+		if cq.Shipment != nil {
+			q.Shipments = append(q.Shipments, *cq.Shipment)
+		}
+		q.Unpriced = append(q.Unpriced, cq.Unpriced...)
+		q.Subtotals[country] += cq.Subtotal
+		q.Total += cq.Subtotal
+	}
+	return q, nil
+}
+
+// --- quantity tiers ---
+
+// TierPricing is the original per-item quantity tier pricing.
+type TierPricing struct {
+	Rules OrderRules
+}
+
+func (t TierPricing) Price(country Country, items Items) (CountryQuote, error) {
+	var cq CountryQuote
+	for _, name := range slices.Sorted(maps.Keys(items)) {
+		quantity := items[name]
+		line := QuoteLine{Country: country, Item: name, Quantity: quantity}
+		if tiers, ok := t.Rules[country][name]; ok && len(tiers) > 0 {
+			line.Tiers = tierCharges(tiers, quantity)
+			for _, c := range line.Tiers {
+				line.Subtotal += c.Cost
+			}
+		} else {
+			line.Unpriced = true
+			cq.Unpriced = append(cq.Unpriced, UnpricedItem{Country: country, Item: name, Quantity: quantity})
+		}
+		cq.Lines = append(cq.Lines, line)
+		cq.Subtotal += line.Subtotal
+	}
+	return cq, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Quote is CalculateTotal itemised: every ordered item with the tiers that
+// priced it, or the parcel it shipped in, so a charge can be explained
+// line by line.
+type Quote struct {
+	RateCard    string          `json:"rateCard,omitempty"` // version, when priced from RateCards
+	Date        string          `json:"date,omitempty"`
+	Lines       []QuoteLine     `json:"lines"`               // sorted by country, then item
+	Shipments   []ShipmentQuote `json:"shipments,omitempty"` // weight-priced countries
+	Subtotals   map[Country]int `json:"subtotals"`
+	Unpriced    []UnpricedItem  `json:"unpriced,omitempty"`
+	Adjustments []Adjustment    `json:"adjustments,omitempty"` // order-level rules, see ApplyPolicies
+	Total       int             `json:"total"`                 // unitless sum of Subtotals
+	Totals      *ShippingTotals `json:"totals,omitempty"`      // per currency, when the rate card declares them
+}
+
+type QuoteLine struct {
+	Country  Country      `json:"country"`
+	Item     Name         `json:"item"`
+	Quantity Quantity     `json:"quantity"`
+	Tiers    []TierCharge `json:"tiers"`
+	Subtotal int          `json:"subtotal"`
+	Unpriced bool         `json:"unpriced,omitempty"` // no rule for the item in its country
+}
+
+// TierCharge is the part of a quantity one tier priced.
+type TierCharge struct {
+	MinQuantity int `json:"minQuantity"`
+	MaxQuantity int `json:"maxQuantity"` // -1 for no upper bound
+	Quantity    int `json:"quantity"`
+	UnitCost    int `json:"unitCost"`
+	Cost        int `json:"cost"`
+}
+
+type UnpricedItem struct {
+	Country  Country  `json:"country"`
+	Item     Name     `json:"item"`
+	Quantity Quantity `json:"quantity"`
+}
+
+// --- main logic ---
+
+// QuoteOrder prices order like CalculateTotal and keeps the details. Its
+// Total always equals CalculateTotal(order, rules).
+func QuoteOrder(order Order, rules OrderRules) Quote {
+	q, _ := QuoteWith(order, Strategies{Default: TierPricing{Rules: rules}}) // tier pricing never fails
+	return q
+}
+
+// QuoteOrderOn is QuoteOrder with the card in effect on date, checked the
+// way CalculateTotalOn checks it.
+func QuoteOrderOn(order Order, cards *RateCards, date time.Time) (Quote, error) {
+	card, err := cards.On(date)
+	if err != nil {
+		return Quote{}, err
+	}
+	if _, err := CalculateTotalStrict(order, card.Rules); err != nil {
+		return Quote{}, fmt.Errorf("rate card %s: %w", card.Version, err)
+	}
+	q := QuoteOrder(order, card.Rules)
+	q.RateCard, q.Date = card.Version, date.Format(dateLayout)
+	if len(card.Countries) > 0 {
+		t, err := TotalsFor(q, card.Countries)
+		if err != nil {
+			return Quote{}, fmt.Errorf("rate card %s: %w", card.Version, err)
+		}
+		q.Totals = &t
+	}
+	return q, nil
+}
+
+// --- rendering ---
+
+// WriteTable renders the quote as an aligned text table, e.g.
+//
+//	COUNTRY  ITEM    QTY  TIER  UNITS  UNIT COST  COST
+//	US       laptop  5    0-2   2      1000       2000
+//	                      3-4   2      950        1900
+func (q Quote) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if q.RateCard != "" {
+		fmt.Fprintf(tw, "Rate card %s, %s\n\n", q.RateCard, q.Date)
+	}
+	fmt.Fprintln(tw, "COUNTRY\tITEM\tQTY\tTIER\tUNITS\tUNIT COST\tCOST")
+	for _, l := range q.Lines {
+		if l.Unpriced {
+			fmt.Fprintf(tw, "%s\t%s\t%d\tno rule\t\t\t0\n", l.Country, l.Item, l.Quantity)
+			continue
+		}
+		if len(l.Tiers) == 0 {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t\t\t\t0\n", l.Country, l.Item, l.Quantity)
+			continue
+		}
+		for i, c := range l.Tiers {
+			if i == 0 {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t", l.Country, l.Item, l.Quantity)
+			} else {
+				fmt.Fprint(tw, "\t\t\t")
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", c.span(), c.Quantity, c.UnitCost, c.Cost)
+		}
+		if len(l.Tiers) > 1 {
+			fmt.Fprintf(tw, "\t\t\t\t\t\t%d\n", l.Subtotal)
+		}
+	}
+	for _, sq := range q.Shipments {
+		for i, it := range sq.Items {
+			if i == 0 {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t", sq.Country, it.Item, it.Quantity)
+			} else {
+				fmt.Fprintf(tw, "\t%s\t%d\t", it.Item, it.Quantity)
+			}
+			fmt.Fprintf(tw, "%d g\t\t\t\n", max(it.ActualGrams, it.VolumetricGrams))
+		}
+		fmt.Fprintf(tw, "\tzone %s\t\t%s\t%d g\t\t%d\n", sq.Zone, sq.Band.span(), sq.ChargeableGrams, sq.Band.Cost)
+		for _, l := range sq.Surcharges {
+			fmt.Fprintf(tw, "\t%s\t\t\t\t\t%d\n", l.Name, l.Amount)
+		}
+	}
+	for _, a := range q.Adjustments {
+		fmt.Fprintf(tw, "%s\t%s\t\t\t\t\t%d\n", a.Country, a.Rule, a.Amount)
+	}
+	for _, country := range slices.Sorted(maps.Keys(q.Subtotals)) {
+		fmt.Fprintf(tw, "%s\t\t\t\t\t\t%d\n", country, q.Subtotals[country])
+	}
+	if q.Totals == nil {
+		fmt.Fprintf(tw, "TOTAL\t\t\t\t\t\t%d\n", q.Total)
+		return tw.Flush()
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNTRY\tCURRENCY\tVAT RATE\tNET\tVAT\tGROSS")
+	for _, c := range q.Totals.Countries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\n", c.Country, c.Currency, percent(c.VATBasisPoints), c.Net, c.VAT, c.Gross)
+	}
+	for _, c := range q.Totals.Currencies {
+		fmt.Fprintf(tw, "TOTAL\t%s\t\t%d\t%d\t%d\n", c.Currency, c.Net, c.VAT, c.Gross)
+	}
+	if r := q.Totals.Reporting; r != nil {
+		fmt.Fprintf(tw, "REPORTED\t%s\t\t%d\t%d\t%d\n", r.Currency, r.Net, r.VAT, r.Gross)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON renders the quote as indented JSON.
+func (q Quote) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(q)
+}
+
+func (b WeightBand) span() string {
+	if b.MaxGrams == -1 {
+		return "any weight"
+	}
+	return fmt.Sprintf("<= %d g", b.MaxGrams)
+}
+
+// percent formats basis points, e.g. 1950 as "19.5%".
+func percent(bp int) string {
+	return strconv.FormatFloat(float64(bp)/100, 'f', -1, 64) + "%"
+}
+
+func (c TierCharge) span() string {
+	return ItemRule{c.MinQuantity, c.MaxQuantity, c.UnitCost}.span()
+}
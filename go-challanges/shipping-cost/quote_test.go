@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuoteOrder(t *testing.T) {
+	rules := OrderRules{
+		"US": {
+			"mouse":  []ItemRule{{0, -1, 550}},
+			"laptop": []ItemRule{{0, 2, 1000}, {3, 4, 950}, {5, -1, 900}},
+		},
+	}
+	order := Order{
+		"US": {"mouse": 20, "laptop": 5, "unknown": 5},
+		"FR": {"mouse": 1},
+	}
+
+	got := QuoteOrder(order, rules)
+	want := Quote{
+		Lines: []QuoteLine{
+			{Country: "FR", Item: "mouse", Quantity: 1, Unpriced: true},
+			{Country: "US", Item: "laptop", Quantity: 5, Subtotal: 4800, Tiers: []TierCharge{
+				{MinQuantity: 0, MaxQuantity: 2, Quantity: 2, UnitCost: 1000, Cost: 2000},
+				{MinQuantity: 3, MaxQuantity: 4, Quantity: 2, UnitCost: 950, Cost: 1900},
+				{MinQuantity: 5, MaxQuantity: -1, Quantity: 1, UnitCost: 900, Cost: 900},
+			}},
+			{Country: "US", Item: "mouse", Quantity: 20, Subtotal: 11000, Tiers: []TierCharge{
+				{MinQuantity: 0, MaxQuantity: -1, Quantity: 20, UnitCost: 550, Cost: 11000},
+			}},
+			{Country: "US", Item: "unknown", Quantity: 5, Unpriced: true},
+		},
+		Subtotals: map[Country]int{"FR": 0, "US": 15800},
+		Unpriced:  []UnpricedItem{{"FR", "mouse", 1}, {"US", "unknown", 5}},
+		Total:     15800,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", got, want)
+	}
+	if total := CalculateTotal(order, rules); total != got.Total {
+		t.Errorf("quote total %d differs from CalculateTotal %d", got.Total, total)
+	}
+}
+
+func TestQuote_Render(t *testing.T) {
+	rules := OrderRules{"US": {"book": []ItemRule{{0, 2, 100}, {3, 5, 80}, {6, -1, 60}}}}
+	q := QuoteOrder(Order{"US": {"book": 6, "pen": 2}}, rules)
+
+	var table bytes.Buffer
+	if err := q.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"US       book  6    0-2      2      100        200", "6+", "pen   2    no rule", "TOTAL", "500"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("table is missing %q:\n%s", want, table.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := q.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var back Quote
+	if err := json.Unmarshal(out.Bytes(), &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, q) {
+		t.Errorf("JSON round trip:\nGot:  %+v\nWant: %+v", back, q)
+	}
+}
+
+func TestReadOrderJSON(t *testing.T) {
+	got, err := ReadOrderJSON(strings.NewReader(`[
+		{"country": "US", "items": [{"name": "pen", "quantity": 1}, {"name": "notebook", "quantity": 4}]},
+		{"country": "US", "items": [{"name": "pen", "quantity": 2}]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Order{"US": {"pen": 3, "notebook": 4}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := ReadOrderJSON(strings.NewReader(`{"items": []}`)); err == nil {
+		t.Error("expected an error for a missing country")
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RateCard is one published version of the shipping rules. It applies to
+// orders placed on or after EffectiveFrom, until the next card starts.
+// Countries, when set, declares the currency and shipping VAT of every
+// country in Rules; without it costs are unitless.
+type RateCard struct {
+	Version       string
+	EffectiveFrom time.Time
+	Rules         OrderRules
+	Countries     map[Country]CountrySettings
+}
+
+var ErrNoRateCard = errors.New("no rate card")
+
+const dateLayout = "2006-01-02"
+
+// RateCards is the history of rate cards, sorted by EffectiveFrom.
+type RateCards struct {
+	cards []RateCard
+}
+
+// NewRateCards checks every card with ValidateRules and ValidateCountries
+// and refuses two cards with the same version or the same EffectiveFrom.
+func NewRateCards(cards ...RateCard) (*RateCards, error) {
+	var errs []error
+	versions := map[string]bool{}
+	for _, c := range cards {
+		switch {
+		case c.Version == "":
+			errs = append(errs, fmt.Errorf("rate card effective %s: missing version", c.EffectiveFrom.Format(dateLayout)))
+		case versions[c.Version]:
+			errs = append(errs, fmt.Errorf("rate card %s: duplicate version", c.Version))
+		}
+		versions[c.Version] = true
+		if err := ValidateRules(c.Rules); err != nil {
+			errs = append(errs, fmt.Errorf("rate card %s: %w", c.Version, err))
+		}
+		if err := c.ValidateCountries(); err != nil {
+			errs = append(errs, fmt.Errorf("rate card %s: %w", c.Version, err))
+		}
+	}
+
+	sorted := slices.Clone(cards)
+	slices.SortStableFunc(sorted, func(a, b RateCard) int { return a.EffectiveFrom.Compare(b.EffectiveFrom) })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].EffectiveFrom.Equal(sorted[i-1].EffectiveFrom) {
+			errs = append(errs, fmt.Errorf("rate cards %s and %s: both effective %s",
+				sorted[i-1].Version, sorted[i].Version, sorted[i].EffectiveFrom.Format(dateLayout)))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &RateCards{cards: sorted}, nil
+}
+
+// On returns the card in effect on date: the latest one effective on or
+// before that day.
+func (rc *RateCards) On(date time.Time) (RateCard, error) {
+	day := truncateDay(date)
+	i, _ := slices.BinarySearchFunc(rc.cards, day, func(c RateCard, d time.Time) int {
+		if truncateDay(c.EffectiveFrom).After(d) {
+			return 1
+		}
+		return -1
+	})
+	if i == 0 {
+		return RateCard{}, fmt.Errorf("%w in effect on %s", ErrNoRateCard, date.Format(dateLayout))
+	}
+	return rc.cards[i-1], nil
+}
+
+// Cards returns every card, oldest first.
+func (rc *RateCards) Cards() []RateCard {
+	return slices.Clone(rc.cards)
+}
+
+// --- main logic ---
+
+// CalculateTotalOn prices order with the card in effect on date, in strict
+// mode, and returns the version it used.
+func CalculateTotalOn(order Order, cards *RateCards, date time.Time) (int, string, error) {
+	card, err := cards.On(date)
+	if err != nil {
+		return 0, "", err
+	}
+	total, err := CalculateTotalStrict(order, card.Rules)
+	if err != nil {
+		return 0, card.Version, fmt.Errorf("rate card %s: %w", card.Version, err)
+	}
+	return total, card.Version, nil
+}
+
+// --- file formats ---
+
+// LoadRateCards reads every file in paths, picking the format from the
+// extension (.json, .yaml, .yml or .csv), and builds the history.
+func LoadRateCards(paths ...string) (*RateCards, error) {
+	var cards []RateCard
+	for _, path := range paths {
+		c, err := loadRateCardFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		cards = append(cards, c...)
+	}
+	return NewRateCards(cards...)
+}
+
+func loadRateCardFile(path string) ([]RateCard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ReadRateCardsJSON(f)
+	case ".yaml", ".yml":
+		return ReadRateCardsYAML(f)
+	case ".csv":
+		return ReadRateCardsCSV(f)
+	}
+	return nil, fmt.Errorf("unsupported extension %q", filepath.Ext(path))
+}
+
+// rateCardFile is the JSON and YAML shape of a card, with the tier keys
+// of the challenge description:
+//
+//	{"version": "2024-05", "effectiveFrom": "2024-05-01",
+//	 "countries": {"US": {"currency": "USD", "vatBasisPoints": 0}},
+//	 "rules": {"US": {"mouse": [{"minQuantity": 0, "maxQuantity": -1, "cost": 550}]}}}
+type rateCardFile struct {
+	Version       string                                `json:"version" yaml:"version"`
+	EffectiveFrom string                                `json:"effectiveFrom" yaml:"effectiveFrom"`
+	Countries     map[Country]CountrySettings           `json:"countries" yaml:"countries"`
+	Rules         map[Country]map[Name][]itemRuleRecord `json:"rules" yaml:"rules"`
+}
+
+type itemRuleRecord struct {
+	MinQuantity *int `json:"minQuantity" yaml:"minQuantity"`
+	MaxQuantity *int `json:"maxQuantity" yaml:"maxQuantity"`
+	Cost        *int `json:"cost" yaml:"cost"`
+}
+
+// ReadRateCardsJSON reads one card object or an array of them.
+func ReadRateCardsJSON(r io.Reader) ([]RateCard, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var files []rateCardFile
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &files)
+	} else {
+		files = make([]rateCardFile, 1)
+		err = json.Unmarshal(data, &files[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rateCardsFromFiles(files)
+}
+
+// ReadRateCardsYAML reads one card per YAML document, in the JSON shape.
+func ReadRateCardsYAML(r io.Reader) ([]RateCard, error) {
+	var files []rateCardFile
+	dec := yaml.NewDecoder(r)
+	for {
+		var f rateCardFile
+		err := dec.Decode(&f)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return rateCardsFromFiles(files)
+}
+
+// ReadRateCardsCSV reads one tier per row, as a spreadsheet exports it:
+//
+//	version,effective_from,country,item,min_quantity,max_quantity,cost
+//	2024-05,2024-05-01,US,mouse,0,-1,550
+//
+// Columns are found by their header name, in any order; a missing or
+// unknown column is an error. Rows of the same version form one card,
+// with the tiers in row order. An empty max_quantity means no upper
+// bound. Two more columns, currency and vat_basis_points, declare the
+// country's settings; every row of a country must then agree on them.
+func ReadRateCardsCSV(r io.Reader) ([]RateCard, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	col, err := csvColumns(rows[0])
+	if err != nil {
+		return nil, err
+	}
+	_, withCountries := col["currency"]
+	field := func(row []string, name string) string { return strings.TrimSpace(row[col[name]]) }
+
+	var cards []RateCard
+	for i, row := range rows[1:] {
+		line := i + 2
+		if len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("line %d: expected %d fields like the header, got %d", line, len(rows[0]), len(row))
+		}
+		version, country, item := field(row, "version"), Country(field(row, "country")), Name(field(row, "item"))
+		from, err := time.Parse(dateLayout, field(row, "effective_from"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var nums [3]int
+		for j, name := range []string{"min_quantity", "max_quantity", "cost"} {
+			s := field(row, name)
+			if name == "max_quantity" && s == "" {
+				nums[j] = -1
+				continue
+			}
+			if nums[j], err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", line, name, err)
+			}
+		}
+
+		k := slices.IndexFunc(cards, func(c RateCard) bool { return c.Version == version })
+		if k < 0 {
+			cards = append(cards, RateCard{Version: version, EffectiveFrom: from, Rules: OrderRules{}})
+			k = len(cards) - 1
+		}
+		card := &cards[k]
+		if !card.EffectiveFrom.Equal(from) {
+			return nil, fmt.Errorf("line %d: version %s is effective %s on an earlier line", line, version, card.EffectiveFrom.Format(dateLayout))
+		}
+		if card.Rules[country] == nil {
+			card.Rules[country] = ItemRules{}
+		}
+		card.Rules[country][item] = append(card.Rules[country][item], ItemRule{nums[0], nums[1], nums[2]})
+
+		if withCountries {
+			vat, err := strconv.Atoi(field(row, "vat_basis_points"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: vat_basis_points: %w", line, err)
+			}
+			s := CountrySettings{Currency: field(row, "currency"), VATBasisPoints: vat}
+			if card.Countries == nil {
+				card.Countries = map[Country]CountrySettings{}
+			}
+			if prev, ok := card.Countries[country]; ok && prev != s {
+				return nil, fmt.Errorf("line %d: %s is %s at %d bp on an earlier line", line, country, prev.Currency, prev.VATBasisPoints)
+			}
+			card.Countries[country] = s
+		}
+	}
+	return cards, nil
+}
+
+// --- helpers ---
+
+var (
+	csvColumnsRequired = []string{"version", "effective_from", "country", "item", "min_quantity", "max_quantity", "cost"}
+	csvColumnsCountry  = []string{"currency", "vat_basis_points"}
+)
+
+// csvColumns maps each column name of header to its index. It needs every
+// required column, both country columns or neither, and nothing else.
+func csvColumns(header []string) (map[string]int, error) {
+	col := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(csvColumnsRequired, name) && !slices.Contains(csvColumnsCountry, name) {
+			return nil, fmt.Errorf("header: unknown column %q", name)
+		}
+		if _, ok := col[name]; ok {
+			return nil, fmt.Errorf("header: column %q appears twice", name)
+		}
+		col[name] = i
+	}
+	for _, name := range csvColumnsRequired {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("header: missing column %q", name)
+		}
+	}
+	_, currency := col["currency"]
+	_, vat := col["vat_basis_points"]
+	if currency != vat {
+		return nil, errors.New("header: currency and vat_basis_points go together")
+	}
+	return col, nil
+}
+
+func rateCardsFromFiles(files []rateCardFile) ([]RateCard, error) {
+	var cards []RateCard
+	for i, f := range files {
+		from, err := time.Parse(dateLayout, f.EffectiveFrom)
+		if err != nil {
+			return nil, fmt.Errorf("card %d: effectiveFrom: %w", i, err)
+		}
+		card := RateCard{Version: f.Version, EffectiveFrom: from, Rules: OrderRules{}, Countries: f.Countries}
+		for country, items := range f.Rules {
+			card.Rules[country] = ItemRules{}
+			for name, tiers := range items {
+				rules := make([]ItemRule, 0, len(tiers))
+				for j, t := range tiers {
+					if t.MinQuantity == nil || t.MaxQuantity == nil || t.Cost == nil {
+						return nil, fmt.Errorf("card %s: %s/%s tier %d: minQuantity, maxQuantity and cost are required", f.Version, country, name, j)
+					}
+					rules = append(rules, ItemRule{*t.MinQuantity, *t.MaxQuantity, *t.Cost})
+				}
+				card.Rules[country][name] = rules
+			}
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
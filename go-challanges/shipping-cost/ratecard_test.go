@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestLoadRateCards_PricesWithCardInEffect(t *testing.T) {
+	cards, err := LoadRateCards("testdata/2024-09.csv", "testdata/2024-01.json", "testdata/2024-05.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := Order{"US": {"mouse": 20, "laptop": 5}}
+
+	cases := []struct {
+		date    time.Time
+		version string
+		want    int
+	}{
+		{day("2024-01-01"), "2024-01", 20*500 + 2*1000 + 3*900},
+		{day("2024-04-30").Add(23 * time.Hour), "2024-01", 20*500 + 2*1000 + 3*900},
+		{day("2024-05-01"), "2024-05", 15800},
+		{day("2024-08-31"), "2024-05", 15800},
+		{day("2025-01-01"), "2024-09", 20*600 + 2*1100 + 3*1000},
+	}
+	for _, c := range cases {
+		got, version, err := CalculateTotalOn(order, cards, c.date)
+		if err != nil || got != c.want || version != c.version {
+			t.Errorf("%s: got %d with %s (%v), want %d with %s", c.date.Format(dateLayout), got, version, err, c.want, c.version)
+		}
+	}
+
+	if _, _, err := CalculateTotalOn(order, cards, day("2023-12-31")); !errors.Is(err, ErrNoRateCard) {
+		t.Errorf("expected ErrNoRateCard before the first card, got %v", err)
+	}
+}
+
+func TestNewRateCards_Errors(t *testing.T) {
+	valid := OrderRules{"US": {"mouse": []ItemRule{{0, -1, 550}}}}
+	cases := []struct {
+		name  string
+		cards []RateCard
+		want  error
+	}{
+		{"invalid rules", []RateCard{{Version: "v1", Rules: OrderRules{"US": {"mouse": []ItemRule{{0, 2, 1}, {2, -1, 1}}}}}}, ErrOverlappingTiers},
+		{"duplicate version", []RateCard{{Version: "v1", Rules: valid}, {Version: "v1", EffectiveFrom: day("2024-01-01"), Rules: valid}}, nil},
+		{"same day", []RateCard{{Version: "v1", Rules: valid}, {Version: "v2", Rules: valid}}, nil},
+	}
+	for _, c := range cases {
+		_, err := NewRateCards(c.cards...)
+		if err == nil || (c.want != nil && !errors.Is(err, c.want)) {
+			t.Errorf("%s: got %v", c.name, err)
+		}
+	}
+}
+
+func TestReadRateCards_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		read func() error
+	}{
+		{"json missing cost", func() error {
+			_, err := ReadRateCardsJSON(strings.NewReader(`{"version": "v1", "effectiveFrom": "2024-01-01", "rules": {"US": {"mouse": [{"minQuantity": 0, "maxQuantity": -1}]}}}`))
+			return err
+		}},
+		{"json bad date", func() error {
+			_, err := ReadRateCardsJSON(strings.NewReader(`[{"version": "v1", "effectiveFrom": "01/01/2024"}]`))
+			return err
+		}},
+		{"yaml bad cost", func() error {
+			_, err := ReadRateCardsYAML(strings.NewReader("version: v1\neffectiveFrom: 2024-01-01\nrules: {US: {mouse: [{minQuantity: 0, maxQuantity: -1, cost: cheap}]}}\n"))
+			return err
+		}},
+		{"csv short row", func() error {
+			_, err := ReadRateCardsCSV(strings.NewReader("version,effective_from,country,item,min_quantity,max_quantity,cost\nv1,2024-01-01,US,mouse,0,-1\n"))
+			return err
+		}},
+		{"csv two dates for a version", func() error {
+			_, err := ReadRateCardsCSV(strings.NewReader("version,effective_from,country,item,min_quantity,max_quantity,cost\nv1,2024-01-01,US,mouse,0,,1\nv1,2024-02-01,US,pen,0,,1\n"))
+			return err
+		}},
+		{"csv missing column", func() error {
+			_, err := ReadRateCardsCSV(strings.NewReader("version,effective_from,country,item,min_quantity,cost\nv1,2024-01-01,US,mouse,0,1\n"))
+			return err
+		}},
+		{"csv unknown column", func() error {
+			_, err := ReadRateCardsCSV(strings.NewReader("version,effective_from,country,item,min_quantity,max_quantity,cost,price\nv1,2024-01-01,US,mouse,0,,1,2\n"))
+			return err
+		}},
+		{"csv currency without VAT", func() error {
+			_, err := ReadRateCardsCSV(strings.NewReader("version,effective_from,country,item,min_quantity,max_quantity,cost,currency\nv1,2024-01-01,US,mouse,0,,1,USD\n"))
+			return err
+		}},
+	}
+	for _, c := range cases {
+		if err := c.read(); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestReadRateCardsCSV_ColumnsByName(t *testing.T) {
+	// cost before the quantities, as a spreadsheet export may reorder them
+	cards, err := ReadRateCardsCSV(strings.NewReader("country,item,cost,max_quantity,min_quantity,effective_from,version\n" +
+		"US,mouse,550,9,0,2024-05-01,2024-05\nUS,mouse,400,,10,2024-05-01,2024-05\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ItemRule{{0, 9, 550}, {10, -1, 400}}
+	if len(cards) != 1 || !slices.Equal(cards[0].Rules["US"]["mouse"], want) {
+		t.Errorf("expected US mouse tiers %v, got %+v", want, cards)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+var (
+	ErrNoTiers          = errors.New("no tiers")
+	ErrInvalidTier      = errors.New("invalid tier")
+	ErrUnsortedTiers    = errors.New("tiers not sorted by minQuantity")
+	ErrOverlappingTiers = errors.New("tiers overlap")
+	ErrTierGap          = errors.New("gap between tiers")
+	ErrOpenEndedTier    = errors.New("open-ended tier must be the last one")
+	ErrNotCovered       = errors.New("quantity not covered by any tier")
+)
+
+// RuleError names the tiers of one item that are wrong. Tier is the index
+// of the offending tier, or -1 when the problem is the list as a whole.
+type RuleError struct {
+	Country Country
+	Item    Name
+	Tier    int
+	Err     error
+}
+
+func (e *RuleError) Error() string {
+	if e.Tier < 0 {
+		return fmt.Sprintf("%s/%s: %v", e.Country, e.Item, e.Err)
+	}
+	return fmt.Sprintf("%s/%s tier %d: %v", e.Country, e.Item, e.Tier, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// RuleErrors flattens an error from ValidateRules or CalculateTotalStrict
+// into its rule errors.
+func RuleErrors(err error) []*RuleError {
+	var out []*RuleError
+	var walk func(error)
+	walk = func(err error) {
+		if re, ok := err.(*RuleError); ok {
+			out = append(out, re)
+			return
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				walk(e)
+			}
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		}
+	}
+	if err != nil {
+		walk(err)
+	}
+	return out
+}
+
+// --- main logic ---
+
+// ValidateRules checks every item's tiers: sorted by minQuantity, starting
+// at 0 or 1, each picking up right after the previous one ends, with at
+// most one open-ended (maxQuantity == -1) tier, and that one last. It
+// returns every problem found, joined, ordered by country and item.
+func ValidateRules(rules OrderRules) error {
+	var errs []error
+	for _, country := range slices.Sorted(maps.Keys(rules)) {
+		for _, name := range slices.Sorted(maps.Keys(rules[country])) {
+			for _, err := range validateTiers(rules[country][name]) {
+				err.Country, err.Item = country, name
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CalculateTotalStrict is CalculateTotal that refuses to price against an
+// invalid rule set, or a quantity the tiers of its item do not reach.
+// Products without rules still cost 0.
+func CalculateTotalStrict(order Order, rules OrderRules) (int, error) {
+	if err := ValidateRules(rules); err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, country := range slices.Sorted(maps.Keys(order)) {
+		for _, name := range slices.Sorted(maps.Keys(order[country])) {
+			tiers := rules[country][name]
+			if q := int(order[country][name]); len(tiers) > 0 && q > coverage(tiers) {
+				errs = append(errs, &RuleError{Country: country, Item: name, Tier: -1, Err: fmt.Errorf("%w: %d", ErrNotCovered, q)})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return 0, errors.Join(errs...)
+	}
+	return CalculateTotal(order, rules), nil
+}
+
+func validateTiers(tiers []ItemRule) []*RuleError {
+	if len(tiers) == 0 {
+		return []*RuleError{{Tier: -1, Err: ErrNoTiers}}
+	}
+
+	var errs []*RuleError
+	tier := func(i int, err error) {
+		errs = append(errs, &RuleError{Tier: i, Err: err})
+	}
+
+	for i, r := range tiers {
+		switch {
+		case r.minQuantity < 0:
+			tier(i, fmt.Errorf("%w: minQuantity %d is negative", ErrInvalidTier, r.minQuantity))
+		case r.maxQuantity != -1 && r.maxQuantity < max(r.minQuantity, 1):
+			tier(i, fmt.Errorf("%w: maxQuantity %d is below minQuantity %d", ErrInvalidTier, r.maxQuantity, r.minQuantity))
+		case r.cost < 0:
+			tier(i, fmt.Errorf("%w: cost %d is negative", ErrInvalidTier, r.cost))
+		}
+		if r.maxQuantity == -1 && i != len(tiers)-1 {
+			tier(i, ErrOpenEndedTier)
+		}
+	}
+	if len(errs) > 0 {
+		return errs // the checks below assume well-formed tiers
+	}
+
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].minQuantity < tiers[i-1].minQuantity {
+			tier(i, ErrUnsortedTiers)
+		}
+	}
+	if len(errs) > 0 {
+		return errs // gaps and overlaps only make sense in order
+	}
+
+	if low := tiers[0].minQuantity; low > 1 {
+		tier(0, fmt.Errorf("%w: quantities 1-%d", ErrTierGap, low-1))
+	}
+	for i := 1; i < len(tiers); i++ {
+		prev, cur := tiers[i-1], tiers[i]
+		switch {
+		case cur.minQuantity <= prev.maxQuantity:
+			tier(i, fmt.Errorf("%w: %s and %s", ErrOverlappingTiers, prev.span(), cur.span()))
+		case cur.minQuantity > prev.maxQuantity+1:
+			tier(i, fmt.Errorf("%w: quantities %d-%d", ErrTierGap, prev.maxQuantity+1, cur.minQuantity-1))
+		}
+	}
+	return errs
+}
+
+func (r ItemRule) span() string {
+	if r.maxQuantity == -1 {
+		return fmt.Sprintf("%d+", r.minQuantity)
+	}
+	return fmt.Sprintf("%d-%d", r.minQuantity, r.maxQuantity)
+}
+
+// coverage returns the highest quantity tiers can price.
+func coverage(tiers []ItemRule) int {
+	last := tiers[len(tiers)-1].maxQuantity
+	if last == -1 {
+		return math.MaxInt
+	}
+	return last
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateRules(t *testing.T) {
+	rules := OrderRules{
+		"US": {
+			"laptop":  []ItemRule{{0, 2, 1000}, {3, 4, 950}, {5, -1, 900}},
+			"gap":     []ItemRule{{1, 2, 100}, {5, -1, 80}},
+			"overlap": []ItemRule{{0, 3, 100}, {2, -1, 80}},
+			"open":    []ItemRule{{0, -1, 100}, {1, -1, 80}},
+		},
+		"DE": {
+			"unsorted": []ItemRule{{3, 5, 80}, {0, 2, 100}},
+			"late":     []ItemRule{{3, -1, 80}},
+			"bad":      []ItemRule{{0, 5, -1}},
+			"empty":    nil,
+		},
+	}
+
+	want := []struct {
+		country Country
+		item    Name
+		tier    int
+		err     error
+	}{
+		{"DE", "bad", 0, ErrInvalidTier},
+		{"DE", "empty", -1, ErrNoTiers},
+		{"DE", "late", 0, ErrTierGap},
+		{"DE", "unsorted", 1, ErrUnsortedTiers},
+		{"US", "gap", 1, ErrTierGap},
+		{"US", "open", 0, ErrOpenEndedTier},
+		{"US", "overlap", 1, ErrOverlappingTiers},
+	}
+
+	got := RuleErrors(ValidateRules(rules))
+	if len(got) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Country != w.country || g.Item != w.item || g.Tier != w.tier || !errors.Is(g, w.err) {
+			t.Errorf("error %d: got %v, want %s/%s tier %d: %v", i, g, w.country, w.item, w.tier, w.err)
+		}
+	}
+
+	if err := ValidateRules(OrderRules{"US": {"laptop": rules["US"]["laptop"]}}); err != nil {
+		t.Errorf("expected valid rules, got %v", err)
+	}
+}
+
+func TestCalculateTotalStrict(t *testing.T) {
+	rules := OrderRules{
+		"US": {
+			"book": []ItemRule{{0, 2, 100}, {3, 5, 80}},
+		},
+	}
+
+	got, err := CalculateTotalStrict(Order{"US": {"book": 4, "unknown": 3}}, rules)
+	if err != nil || got != 2*100+2*80 {
+		t.Fatalf("expected %d, got %d, %v", 2*100+2*80, got, err)
+	}
+
+	_, err = CalculateTotalStrict(Order{"US": {"book": 6}}, rules)
+	if !errors.Is(err, ErrNotCovered) {
+		t.Errorf("expected ErrNotCovered, got %v", err)
+	}
+
+	rules["US"]["book"] = []ItemRule{{0, 2, 100}, {2, -1, 80}}
+	got, err = CalculateTotalStrict(Order{"US": {"book": 4}}, rules)
+	if !errors.Is(err, ErrOverlappingTiers) || got != 0 {
+		t.Errorf("expected ErrOverlappingTiers, got %d, %v", got, err)
+	}
+}
+
+func TestQuantityBelowLaterTiers(t *testing.T) {
+	rules := OrderRules{"US": {"laptop": []ItemRule{{0, 2, 1000}, {3, 4, 950}, {5, -1, 900}}}}
+
+	got := CalculateTotal(Order{"US": {"laptop": 1}}, rules)
+	if got != 1000 {
+		t.Fatalf("expected 1000, got %d", got)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RateCardStore holds the rate cards loaded from a set of files and loads
+// them again when one of the files changes. A reload that fails keeps the
+// cards already in use.
+type RateCardStore struct {
+	paths  []string
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	cards  *RateCards
+	stamps []fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewRateCardStore loads paths once; unlike a later reload, this has to
+// succeed.
+func NewRateCardStore(logger *slog.Logger, paths ...string) (*RateCardStore, error) {
+	s := &RateCardStore{paths: slices.Clone(paths), logger: logger}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RateCardStore) Cards() *RateCards {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cards
+}
+
+// Reload loads the files again if any of them changed size or modification
+// time since the last load, and reports whether it did.
+func (s *RateCardStore) Reload() (bool, error) {
+	stamps, err := s.stat()
+	if err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	unchanged := s.cards != nil && slices.Equal(stamps, s.stamps)
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cards, err := LoadRateCards(s.paths...)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	s.cards, s.stamps = cards, stamps
+	s.mu.Unlock()
+
+	versions := make([]string, 0, len(cards.cards))
+	for _, c := range cards.cards {
+		versions = append(versions, c.Version)
+	}
+	s.logger.Info("rate cards loaded", "files", s.paths, "versions", versions)
+	return true, nil
+}
+
+// Watch calls Reload every interval until ctx is done.
+func (s *RateCardStore) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reload(); err != nil {
+				s.logger.Error("rate card reload failed, keeping the previous cards", "error", err)
+			}
+		}
+	}
+}
+
+func (s *RateCardStore) stat() ([]fileStamp, error) {
+	stamps := make([]fileStamp, len(s.paths))
+	for i, path := range s.paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		stamps[i] = fileStamp{fi.ModTime(), fi.Size()}
+	}
+	return stamps, nil
+}
+
+// --- HTTP ---
+
+const maxOrderBytes = 1 << 20
+
+// Server answers POST /quote with the Quote of the order in the body, in
+// the shape ReadOrderJSON reads. Query parameters:
+//
+//	date    order date, YYYY-MM-DD (default today)
+//	report  reporting currency for the totals
+//	fx      rates for report, as the -fx flag: EUR=4.97,USD=4.58
+type Server struct {
+	Store  *RateCardStore
+	Logger *slog.Logger
+	Now    func() time.Time // time.Now when nil
+}
+
+type errorResponse struct {
+	Error   string   `json:"error"`
+	Details []string `json:"details,omitempty"`
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /quote", s.quote)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return s.logRequests(mux)
+}
+
+func (s *Server) quote(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type %q, want application/json", ct))
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	date := s.now()
+	if d := query.Get("date"); d != "" {
+		var err error
+		if date, err = time.Parse(dateLayout, d); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("date: %w", err))
+			return
+		}
+	}
+	rates, err := parseRates(query.Get("fx"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	order, err := ReadOrderJSON(http.MaxBytesReader(w, r.Body, maxOrderBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(order) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("empty order"))
+		return
+	}
+
+	q, err := QuoteOrderOn(order, s.Store.Cards(), date)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if report := query.Get("report"); report != "" {
+		if q.Totals == nil {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("rate card %s declares no currencies", q.RateCard))
+			return
+		}
+		if err := q.Totals.Report(report, rates); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := q.WriteJSON(w); err != nil {
+		s.Logger.Error("writing quote", "error", err)
+	}
+}
+
+// --- helpers ---
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		s.Logger.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)))
+	})
+}
+
+// writeError answers with the error, or one detail per item when the
+// rate card does not cover the order.
+func writeError(w http.ResponseWriter, status int, err error) {
+	resp := errorResponse{Error: err.Error()}
+	if rules := RuleErrors(err); len(rules) > 0 {
+		resp.Error = "order not covered by the rate card"
+		for _, re := range rules {
+			resp.Details = append(resp.Details, re.Error())
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serve runs the quote service on addr until it fails, checking the rate
+// card files for changes every reload.
+func serve(addr string, paths []string, reload time.Duration) error {
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	store, err := NewRateCardStore(logger, paths...)
+	if err != nil {
+		return err
+	}
+	go store.Watch(context.Background(), reload)
+
+	srv := &Server{Store: store, Logger: logger}
+	logger.Info("listening", "addr", addr)
+	return http.ListenAndServe(addr, srv.Handler())
+}
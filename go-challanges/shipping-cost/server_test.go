@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, paths ...string) (*httptest.Server, *RateCardStore) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, err := NewRateCardStore(logger, paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Store: store, Logger: logger, Now: func() time.Time { return day("2024-10-01") }}
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func postQuote(t *testing.T, server *httptest.Server, query, contentType, body string) (int, []byte) {
+	t.Helper()
+	resp, err := server.Client().Post(server.URL+"/quote"+query, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, contents
+}
+
+func TestServer_Quote(t *testing.T) {
+	server, _ := newTestServer(t, "testdata/2024-01.json", "testdata/2024-05.yaml", "testdata/2024-09.csv")
+
+	data := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		code        int
+		total       int
+		errMsg      string
+	}{
+		{"today", "", "application/json", `{"country": "US", "items": [{"name": "mouse", "quantity": 2}]}`, http.StatusOK, 1200, ""},
+		{"dated", "?date=2024-05-01", "application/json; charset=utf-8", `[{"country": "US", "items": [{"name": "laptop", "quantity": 5}]}]`, http.StatusOK, 4800, ""},
+		{"bad date", "?date=01/05/2024", "application/json", `{"country": "US", "items": []}`, http.StatusBadRequest, 0, `date: parsing time "01/05/2024" as "2006-01-02": cannot parse "01/05/2024" as "2006"`},
+		{"bad json", "", "application/json", `{"country": "US", "items": [`, http.StatusBadRequest, 0, "unexpected end of JSON input"},
+		{"missing country", "", "application/json", `{"items": [{"name": "mouse", "quantity": 2}]}`, http.StatusBadRequest, 0, "order 0: missing country"},
+		{"negative quantity", "", "application/json", `{"country": "US", "items": [{"name": "mouse", "quantity": -1}]}`, http.StatusBadRequest, 0, `order 0: invalid item "mouse" quantity -1`},
+		{"empty", "", "application/json", `[]`, http.StatusBadRequest, 0, "empty order"},
+		{"not json", "", "text/plain", `US mouse 2`, http.StatusUnsupportedMediaType, 0, `content type "text/plain", want application/json`},
+		{"before every card", "?date=2023-12-31", "application/json", `{"country": "US", "items": [{"name": "mouse", "quantity": 2}]}`, http.StatusUnprocessableEntity, 0, "no rate card in effect on 2023-12-31"},
+		{"no currency rate", "?report=RON", "application/json", `{"country": "DE", "items": [{"name": "laptop", "quantity": 1}]}`, http.StatusUnprocessableEntity, 0, "no conversion rate for EUR/RON"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			code, body := postQuote(t, server, d.query, d.contentType, d.body)
+			if code != d.code {
+				t.Errorf("expected status %d, got %d: %s", d.code, code, body)
+			}
+			var result struct {
+				Total int    `json:"total"`
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != d.total {
+				t.Errorf("expected total %d, got %d", d.total, result.Total)
+			}
+			if result.Error != d.errMsg {
+				t.Errorf("expected error `%s`, got `%s`", d.errMsg, result.Error)
+			}
+		})
+	}
+}
+
+func TestServer_QuoteReportsTotals(t *testing.T) {
+	server, _ := newTestServer(t, "testdata/2024-09.csv")
+
+	code, body := postQuote(t, server, "?report=RON&fx=EUR=4.97,USD=4.5813", "application/json",
+		`[{"country": "US", "items": [{"name": "mouse", "quantity": 3}]}, {"country": "DE", "items": [{"name": "laptop", "quantity": 2}]}]`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	var q Quote
+	if err := json.Unmarshal(body, &q); err != nil {
+		t.Fatal(err)
+	}
+	want := CurrencyTotal{"RON", 20174, 2266, 22440}
+	if q.Totals == nil || q.Totals.Reporting == nil || *q.Totals.Reporting != want {
+		t.Errorf("expected reporting %+v, got %+v", want, q.Totals)
+	}
+}
+
+func TestServer_QuoteNotCovered(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "card.json")
+	writeCard(t, path, "v1", 2, 100)
+	server, _ := newTestServer(t, path)
+
+	code, body := postQuote(t, server, "", "application/json", `{"country": "US", "items": [{"name": "mouse", "quantity": 3}]}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", code, body)
+	}
+	var resp errorResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Details) != 1 || resp.Details[0] != "US/mouse: quantity not covered by any tier: 3" {
+		t.Errorf("unexpected details %q", resp.Details)
+	}
+}
+
+func TestRateCardStore_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "card.json")
+	writeCard(t, path, "v1", -1, 100)
+	server, store := newTestServer(t, path)
+	order := `{"country": "US", "items": [{"name": "mouse", "quantity": 3}]}`
+
+	if reloaded, err := store.Reload(); reloaded || err != nil {
+		t.Errorf("unchanged files: got %v, %v", reloaded, err)
+	}
+
+	writeCard(t, path, "v2", -1, 200)
+	if reloaded, err := store.Reload(); !reloaded || err != nil {
+		t.Fatalf("changed file: got %v, %v", reloaded, err)
+	}
+	_, body := postQuote(t, server, "", "application/json", order)
+	var q Quote
+	if err := json.Unmarshal(body, &q); err != nil {
+		t.Fatal(err)
+	}
+	if q.RateCard != "v2" || q.Total != 600 {
+		t.Errorf("expected v2 pricing 600, got %s pricing %d", q.RateCard, q.Total)
+	}
+
+	// a broken file keeps the cards in use
+	if err := os.WriteFile(path, []byte(`{"version": "v3", "effectiveFrom": "oops"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, path, 2)
+	if _, err := store.Reload(); err == nil {
+		t.Error("expected an error for a broken file")
+	}
+	if card, _ := store.Cards().On(day("2024-10-01")); card.Version != "v2" {
+		t.Errorf("expected v2 to stay in use, got %s", card.Version)
+	}
+}
+
+// writeCard writes a one-tier card for US mice, modified cost seconds
+// into 2024 so that every cost looks like a new file.
+func writeCard(t *testing.T, path, version string, maxQuantity, cost int) {
+	t.Helper()
+	card := fmt.Sprintf(`{"version": %q, "effectiveFrom": "2024-01-01", "rules": {"US": {"mouse": [{"minQuantity": 0, "maxQuantity": %d, "cost": %d}]}}}`,
+		version, maxQuantity, cost)
+	if err := os.WriteFile(path, []byte(card), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, path, cost)
+}
+
+func touch(t *testing.T, path string, seconds int) {
+	t.Helper()
+	mtime := time.Date(2024, 1, 1, 0, 0, seconds, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+)
+
+// CountrySettings is what a rate card declares for a country: the currency
+// its costs are in, as minor units, and the VAT charged on shipping. Costs
+// are net of VAT.
+type CountrySettings struct {
+	Currency       string `json:"currency" yaml:"currency"`
+	VATBasisPoints int    `json:"vatBasisPoints" yaml:"vatBasisPoints"` // 1900 is 19%
+}
+
+// CountryTotal is the shipping to one country with its VAT.
+type CountryTotal struct {
+	Country        Country `json:"country"`
+	Currency       string  `json:"currency"`
+	VATBasisPoints int     `json:"vatBasisPoints"`
+	Net            int     `json:"net"`
+	VAT            int     `json:"vat"`
+	Gross          int     `json:"gross"`
+}
+
+// CurrencyTotal adds up the countries charged in one currency.
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	Net      int    `json:"net"`
+	VAT      int    `json:"vat"`
+	Gross    int    `json:"gross"`
+}
+
+type ShippingTotals struct {
+	Countries  []CountryTotal  `json:"countries"`           // sorted by country
+	Currencies []CurrencyTotal `json:"currencies"`          // sorted by currency
+	Reporting  *CurrencyTotal  `json:"reporting,omitempty"` // see Report
+
+	rates map[string]float64 // Reporting was converted with
+}
+
+var (
+	ErrNoCountrySettings = errors.New("no currency and VAT settings")
+	ErrInvalidCurrency   = errors.New("currency must be a three-letter ISO 4217 code")
+	ErrInvalidVAT        = errors.New("VAT must be between 0 and 10000 basis points")
+	ErrNoConversionRate  = errors.New("no conversion rate")
+)
+
+// minorUnits lists the currencies whose minor unit is not a hundredth.
+var minorUnits = map[string]int{
+	"JPY": 0, "KRW": 0, "ISK": 0, "CLP": 0, "VND": 0,
+	"KWD": 3, "BHD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// --- main logic ---
+
+// TotalsFor splits the quote's subtotal of every country into net, VAT and
+// gross with settings, and adds them up per currency. VAT is rounded half
+// up once per country.
+func TotalsFor(q Quote, settings map[Country]CountrySettings) (ShippingTotals, error) {
+	var (
+		t    ShippingTotals
+		errs []error
+	)
+	byCurrency := map[string]*CurrencyTotal{}
+	for _, country := range slices.Sorted(maps.Keys(q.Subtotals)) {
+		s, ok := settings[country]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %w", country, ErrNoCountrySettings))
+			continue
+		}
+		net := q.Subtotals[country]
+		vat := (net*s.VATBasisPoints + 5000) / 10000
+		ct := CountryTotal{Country: country, Currency: s.Currency, VATBasisPoints: s.VATBasisPoints, Net: net, VAT: vat, Gross: net + vat}
+		t.Countries = append(t.Countries, ct)
+
+		cur, ok := byCurrency[s.Currency]
+		if !ok {
+			cur = &CurrencyTotal{Currency: s.Currency}
+			byCurrency[s.Currency] = cur
+		}
+		cur.Net += ct.Net
+		cur.VAT += ct.VAT
+		cur.Gross += ct.Gross
+	}
+	if len(errs) > 0 {
+		return ShippingTotals{}, errors.Join(errs...)
+	}
+	for _, c := range byCurrency {
+		t.Currencies = append(t.Currencies, *c)
+	}
+	slices.SortFunc(t.Currencies, func(a, b CurrencyTotal) int { return cmp.Compare(a.Currency, b.Currency) })
+	return t, nil
+}
+
+// ConvertTo adds up every currency in the reporting currency to. rates
+// holds the price of one unit of each other currency in to, e.g.
+// {"EUR": 4.97} when reporting in RON. Net and VAT are converted and
+// rounded half up per currency, and Gross is their sum.
+func (t ShippingTotals) ConvertTo(to string, rates map[string]float64) (CurrencyTotal, error) {
+	out := CurrencyTotal{Currency: to}
+	for _, c := range t.Currencies {
+		rate := big.NewRat(1, 1)
+		if c.Currency != to {
+			r, ok := rates[c.Currency]
+			if !ok || r <= 0 {
+				return CurrencyTotal{}, fmt.Errorf("%w for %s/%s", ErrNoConversionRate, c.Currency, to)
+			}
+			rate.SetString(strconv.FormatFloat(r, 'f', -1, 64)) // the decimal the caller wrote, not its binary neighbour
+		}
+		out.Net += convert(c.Net, rate, c.Currency, to)
+		out.VAT += convert(c.VAT, rate, c.Currency, to)
+	}
+	out.Gross = out.Net + out.VAT
+	return out, nil
+}
+
+// Report sets Reporting to the totals converted to the currency to (see
+// ConvertTo), and keeps rates so ApplyPolicies can convert again.
+func (t *ShippingTotals) Report(to string, rates map[string]float64) error {
+	r, err := t.ConvertTo(to, rates)
+	if err != nil {
+		return err
+	}
+	t.Reporting, t.rates = &r, maps.Clone(rates)
+	return nil
+}
+
+// ValidateCountries checks the settings of a card that declares any: a
+// currency code and a VAT rate for every country it has rules for.
+func (c RateCard) ValidateCountries() error {
+	if len(c.Countries) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, country := range slices.Sorted(maps.Keys(c.Countries)) {
+		s := c.Countries[country]
+		if !isCurrencyCode(s.Currency) {
+			errs = append(errs, fmt.Errorf("%s: %w, got %q", country, ErrInvalidCurrency, s.Currency))
+		}
+		if s.VATBasisPoints < 0 || s.VATBasisPoints > 10000 {
+			errs = append(errs, fmt.Errorf("%s: %w, got %d", country, ErrInvalidVAT, s.VATBasisPoints))
+		}
+	}
+	for _, country := range slices.Sorted(maps.Keys(c.Rules)) {
+		if _, ok := c.Countries[country]; !ok {
+			errs = append(errs, fmt.Errorf("%s: %w", country, ErrNoCountrySettings))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// --- helpers ---
+
+// settings recovers what t was computed with, to compute it again.
+func (t ShippingTotals) settings() map[Country]CountrySettings {
+	s := make(map[Country]CountrySettings, len(t.Countries))
+	for _, c := range t.Countries {
+		s[c.Country] = CountrySettings{Currency: c.Currency, VATBasisPoints: c.VATBasisPoints}
+	}
+	return s
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func exponent(currency string) int {
+	if e, ok := minorUnits[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// convert turns minor units of from into minor units of to, half up.
+func convert(amount int, rate *big.Rat, from, to string) int {
+	v := new(big.Rat).Mul(big.NewRat(int64(amount), 1), rate)
+	shift := exponent(to) - exponent(from)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil))
+	if shift >= 0 {
+		v.Mul(v, scale)
+	} else {
+		v.Quo(v, scale)
+	}
+
+	// round half away from zero
+	num, den := new(big.Int).Abs(v.Num()), v.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Lsh(r, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	return int(q.Int64())
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTotalsFor(t *testing.T) {
+	rules := OrderRules{
+		"DE": {"laptop": []ItemRule{{0, -1, 1299}}},
+		"FR": {"laptop": []ItemRule{{0, -1, 1000}}},
+		"US": {"laptop": []ItemRule{{0, -1, 1500}}},
+	}
+	settings := map[Country]CountrySettings{
+		"DE": {"EUR", 1900},
+		"FR": {"EUR", 2000},
+		"US": {"USD", 0},
+	}
+	q := QuoteOrder(Order{"DE": {"laptop": 1}, "FR": {"laptop": 2}, "US": {"laptop": 1}}, rules)
+
+	got, err := TotalsFor(q, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ShippingTotals{
+		Countries: []CountryTotal{
+			{"DE", "EUR", 1900, 1299, 247, 1546}, // 246.81
+			{"FR", "EUR", 2000, 2000, 400, 2400},
+			{"US", "USD", 0, 1500, 0, 1500},
+		},
+		Currencies: []CurrencyTotal{
+			{"EUR", 3299, 647, 3946},
+			{"USD", 1500, 0, 1500},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	delete(settings, "FR")
+	if _, err := TotalsFor(q, settings); !errors.Is(err, ErrNoCountrySettings) {
+		t.Errorf("expected ErrNoCountrySettings, got %v", err)
+	}
+}
+
+func TestShippingTotals_ConvertTo(t *testing.T) {
+	totals := ShippingTotals{Currencies: []CurrencyTotal{
+		{"EUR", 2400, 456, 2856},
+		{"JPY", 1500, 150, 1650},
+		{"RON", 1000, 190, 1190},
+	}}
+	rates := map[string]float64{"EUR": 4.97, "JPY": 0.0305}
+
+	got, err := totals.ConvertTo("RON", rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// EUR 24.00 + 4.56 VAT, JPY 1500 + 150 VAT (no minor unit), RON as is
+	want := CurrencyTotal{"RON", 11928 + 4575 + 1000, 2266 + 458 + 190, 0}
+	want.Gross = want.Net + want.VAT
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := totals.ConvertTo("USD", rates); !errors.Is(err, ErrNoConversionRate) {
+		t.Errorf("expected ErrNoConversionRate, got %v", err)
+	}
+}
+
+func TestRateCard_ValidateCountries(t *testing.T) {
+	rules := OrderRules{"DE": {"laptop": []ItemRule{{0, -1, 1200}}}, "US": {"laptop": []ItemRule{{0, -1, 1000}}}}
+	cases := []struct {
+		name      string
+		countries map[Country]CountrySettings
+		want      error
+	}{
+		{"undeclared", nil, nil},
+		{"complete", map[Country]CountrySettings{"DE": {"EUR", 1900}, "US": {"USD", 0}}, nil},
+		{"missing country", map[Country]CountrySettings{"DE": {"EUR", 1900}}, ErrNoCountrySettings},
+		{"bad currency", map[Country]CountrySettings{"DE": {"eur", 1900}, "US": {"USD", 0}}, ErrInvalidCurrency},
+		{"bad VAT", map[Country]CountrySettings{"DE": {"EUR", 19000}, "US": {"USD", 0}}, ErrInvalidVAT},
+	}
+	for _, c := range cases {
+		err := RateCard{Version: "v1", Rules: rules, Countries: c.countries}.ValidateCountries()
+		if (c.want == nil) != (err == nil) || (c.want != nil && !errors.Is(err, c.want)) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestQuoteOrderOn_Totals(t *testing.T) {
+	cards, err := LoadRateCards("testdata/2024-01.json", "testdata/2024-09.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := Order{"US": {"mouse": 3}, "DE": {"laptop": 2}}
+
+	q, err := QuoteOrderOn(order, cards, day("2024-10-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Totals == nil {
+		t.Fatal("expected totals from a card with currencies")
+	}
+	want := []CurrencyTotal{{"EUR", 2400, 456, 2856}, {"USD", 1800, 0, 1800}}
+	if !reflect.DeepEqual(q.Totals.Currencies, want) {
+		t.Errorf("got %+v, want %+v", q.Totals.Currencies, want)
+	}
+
+	// order-level rules keep the totals and their reporting in step
+	if err := q.Totals.Report("EUR", map[string]float64{"USD": 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	capped, err := ApplyPolicies(q, ShippingPolicies{"DE": {Cap: 2000}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := capped.Totals.Currencies[0]; got != (CurrencyTotal{"EUR", 2000, 380, 2380}) {
+		t.Errorf("capped EUR: got %+v", got)
+	}
+	// 2000 + 1620 net, 380 VAT
+	if got := capped.Totals.Reporting; got == nil || *got != (CurrencyTotal{"EUR", 3620, 380, 4000}) {
+		t.Errorf("capped reporting: got %+v", got)
+	}
+	if got := *q.Totals.Reporting; got != (CurrencyTotal{"EUR", 4020, 456, 4476}) {
+		t.Errorf("the original quote changed: got %+v", got)
+	}
+
+	old, err := QuoteOrderOn(Order{"US": {"mouse": 3}}, cards, day("2024-02-01"))
+	if err != nil || old.Totals != nil {
+		t.Errorf("card without currencies: got %+v (%v)", old.Totals, err)
+	}
+}
+
+func TestApplyPolicies_TotalsError(t *testing.T) {
+	q := Quote{
+		Subtotals: map[Country]int{"DE": 1000, "FR": 500},
+		Total:     1500,
+		Totals:    &ShippingTotals{Countries: []CountryTotal{{Country: "DE", Currency: "EUR", VATBasisPoints: 1900}}},
+	}
+	if _, err := ApplyPolicies(q, nil, nil); !errors.Is(err, ErrNoCountrySettings) {
+		t.Errorf("expected ErrNoCountrySettings, got %v", err)
+	}
+
+	q.Subtotals = map[Country]int{"DE": 1000}
+	q.Totals.Reporting = &CurrencyTotal{Currency: "USD"} // set without Report, so no rates
+	if _, err := ApplyPolicies(q, nil, nil); !errors.Is(err, ErrNoConversionRate) {
+		t.Errorf("expected ErrNoConversionRate, got %v", err)
+	}
+}
+
+func TestReadRateCardsCSV_Countries(t *testing.T) {
+	header := "version,effective_from,country,item,min_quantity,max_quantity,cost,currency,vat_basis_points\n"
+	cards, err := ReadRateCardsCSV(strings.NewReader(header + "v1,2024-01-01,DE,laptop,0,,1200,EUR,1900\nv1,2024-01-01,DE,mouse,0,,300,EUR,1900\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cards[0].Countries["DE"]; got != (CountrySettings{"EUR", 1900}) {
+		t.Errorf("got %+v", got)
+	}
+
+	if _, err := ReadRateCardsCSV(strings.NewReader(header + "v1,2024-01-01,DE,laptop,0,,1200,EUR,1900\nv1,2024-01-01,DE,mouse,0,,300,EUR,700\n")); err == nil {
+		t.Error("expected an error for two VAT rates in one country")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Product is the shipping metadata of one unit of an item.
+//
+// It lives in a Catalog next to Items and ItemRules rather than on them:
+// Items is the quantity of each item in an order and ItemRules its price
+// tiers in one country, while weight and size belong to the item itself,
+// the same in every country. A Catalog is keyed by the same Name, so one
+// entry serves every country priced by weight.
+type Product struct {
+	WeightGrams int
+	LengthCm    int
+	WidthCm     int
+	HeightCm    int
+}
+
+type Catalog map[Name]Product
+
+type Zone string
+
+// WeightBand charges Cost for a parcel up to MaxGrams (-1 for no limit).
+type WeightBand struct {
+	MaxGrams int `json:"maxGrams"`
+	Cost     int `json:"cost"`
+}
+
+// Surcharges are added to the band cost of a parcel. Fuel is charged on
+// the band cost plus the other surcharges.
+type Surcharges struct {
+	FuelBasisPoints int          // 1250 is 12.5%
+	Remote          map[Zone]int // flat, for zones that are hard to reach
+	OversizeCm      int          // longest side above this is oversize, 0 disables
+	OversizeGrams   int          // unit weight above this is oversize, 0 disables
+	Oversize        int          // per oversize unit
+}
+
+var (
+	ErrNoZone     = errors.New("no zone for country")
+	ErrOverweight = errors.New("parcel heavier than every weight band")
+	ErrBadBands   = errors.New("invalid weight bands")
+)
+
+// WeightPricing charges every country's items as one parcel, by the
+// heavier of its actual and volumetric weight, from the bands of the
+// country's zone. Price checks those bands first (see ValidateWeightBands)
+// rather than pick the wrong one.
+type WeightPricing struct {
+	Catalog           Catalog
+	Zones             map[Country]Zone
+	Bands             map[Zone][]WeightBand // sorted by MaxGrams
+	VolumetricDivisor int                   // cm³ per kg, 5000 when 0
+	Surcharges        Surcharges
+}
+
+// ShipmentQuote is the weight-priced parcel for one country.
+type ShipmentQuote struct {
+	Country         Country         `json:"country"`
+	Zone            Zone            `json:"zone"`
+	Items           []ParcelItem    `json:"items"`
+	ChargeableGrams int             `json:"chargeableGrams"`
+	Band            WeightBand      `json:"band"`
+	Surcharges      []SurchargeLine `json:"surcharges,omitempty"`
+	Subtotal        int             `json:"subtotal"`
+}
+
+type ParcelItem struct {
+	Item            Name     `json:"item"`
+	Quantity        Quantity `json:"quantity"`
+	ActualGrams     int      `json:"actualGrams"`     // per unit
+	VolumetricGrams int      `json:"volumetricGrams"` // per unit
+	Oversize        bool     `json:"oversize,omitempty"`
+}
+
+type SurchargeLine struct {
+	Name   string `json:"name"`
+	Amount int    `json:"amount"`
+}
+
+// --- main logic ---
+
+func (w WeightPricing) Price(country Country, items Items) (CountryQuote, error) {
+	zone, ok := w.Zones[country]
+	if !ok {
+		return CountryQuote{}, fmt.Errorf("%w %s", ErrNoZone, country)
+	}
+	if errs := validateBands(zone, w.Bands[zone]); len(errs) > 0 {
+		return CountryQuote{}, fmt.Errorf("%w: %w", ErrBadBands, errors.Join(errs...))
+	}
+
+	var cq CountryQuote
+	sq := ShipmentQuote{Country: country, Zone: zone}
+	oversize := 0
+	for _, name := range slices.Sorted(maps.Keys(items)) {
+		quantity := items[name]
+		if quantity <= 0 {
+			continue
+		}
+		p, ok := w.Catalog[name]
+		if !ok {
+			cq.Unpriced = append(cq.Unpriced, UnpricedItem{Country: country, Item: name, Quantity: quantity})
+			continue
+		}
+		item := ParcelItem{
+			Item:            name,
+			Quantity:        quantity,
+			ActualGrams:     p.WeightGrams,
+			VolumetricGrams: w.volumetricGrams(p),
+			Oversize:        w.Surcharges.isOversize(p),
+		}
+		sq.Items = append(sq.Items, item)
+		sq.ChargeableGrams += max(item.ActualGrams, item.VolumetricGrams) * int(quantity)
+		if item.Oversize {
+			oversize += int(quantity)
+		}
+	}
+	if len(sq.Items) == 0 {
+		return cq, nil // nothing to ship
+	}
+
+	band, err := pickBand(w.Bands[zone], sq.ChargeableGrams)
+	if err != nil {
+		return CountryQuote{}, err
+	}
+	sq.Band = band
+
+	s := w.Surcharges
+	if fee := s.Remote[zone]; fee > 0 {
+		sq.Surcharges = append(sq.Surcharges, SurchargeLine{"remote area", fee})
+	}
+	if oversize > 0 && s.Oversize > 0 {
+		sq.Surcharges = append(sq.Surcharges, SurchargeLine{fmt.Sprintf("oversize x%d", oversize), oversize * s.Oversize})
+	}
+	base := band.Cost
+	for _, l := range sq.Surcharges {
+		base += l.Amount
+	}
+	if s.FuelBasisPoints > 0 {
+		// rounded half up to a whole unit
+		sq.Surcharges = append(sq.Surcharges, SurchargeLine{"fuel", (base*s.FuelBasisPoints + 5000) / 10000})
+	}
+
+	sq.Subtotal = band.Cost
+	for _, l := range sq.Surcharges {
+		sq.Subtotal += l.Amount
+	}
+	cq.Shipment = &sq
+	cq.Subtotal = sq.Subtotal
+	return cq, nil
+}
+
+// ValidateWeightBands checks every zone's bands are sorted by MaxGrams,
+// with non-negative costs and only the last one open-ended.
+func ValidateWeightBands(bands map[Zone][]WeightBand) error {
+	var errs []error
+	for _, zone := range slices.Sorted(maps.Keys(bands)) {
+		errs = append(errs, validateBands(zone, bands[zone])...)
+	}
+	return errors.Join(errs...)
+}
+
+// --- helpers ---
+
+// volumetricGrams is L×W×H / divisor, in grams rounded up.
+func (w WeightPricing) volumetricGrams(p Product) int {
+	divisor := w.VolumetricDivisor
+	if divisor <= 0 {
+		divisor = 5000
+	}
+	cm3 := p.LengthCm * p.WidthCm * p.HeightCm
+	return (cm3*1000 + divisor - 1) / divisor
+}
+
+func (s Surcharges) isOversize(p Product) bool {
+	longest := max(p.LengthCm, p.WidthCm, p.HeightCm)
+	return (s.OversizeCm > 0 && longest > s.OversizeCm) || (s.OversizeGrams > 0 && p.WeightGrams > s.OversizeGrams)
+}
+
+func validateBands(zone Zone, bands []WeightBand) []error {
+	var errs []error
+	if len(bands) == 0 {
+		errs = append(errs, fmt.Errorf("zone %s: no weight bands", zone))
+	}
+	for i, b := range bands {
+		switch {
+		case b.Cost < 0:
+			errs = append(errs, fmt.Errorf("zone %s band %d: cost %d is negative", zone, i, b.Cost))
+		case b.MaxGrams == -1 && i != len(bands)-1:
+			errs = append(errs, fmt.Errorf("zone %s band %d: open-ended band must be the last one", zone, i))
+		case b.MaxGrams != -1 && b.MaxGrams <= 0:
+			errs = append(errs, fmt.Errorf("zone %s band %d: maxGrams %d must be positive", zone, i, b.MaxGrams))
+		case i > 0 && b.MaxGrams != -1 && b.MaxGrams <= bands[i-1].MaxGrams:
+			errs = append(errs, fmt.Errorf("zone %s band %d: not sorted by maxGrams", zone, i))
+		}
+	}
+	return errs
+}
+
+func pickBand(bands []WeightBand, grams int) (WeightBand, error) {
+	for _, b := range bands {
+		if b.MaxGrams == -1 || grams <= b.MaxGrams {
+			return b, nil
+		}
+	}
+	return WeightBand{}, fmt.Errorf("%w: %d g", ErrOverweight, grams)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func weightPricing() WeightPricing {
+	return WeightPricing{
+		Catalog: Catalog{
+			"laptop": {WeightGrams: 2500, LengthCm: 40, WidthCm: 30, HeightCm: 5}, // volumetric 1200 g
+			"pillow": {WeightGrams: 500, LengthCm: 50, WidthCm: 40, HeightCm: 20}, // volumetric 8000 g
+		},
+		Zones: map[Country]Zone{"DE": "EU", "IS": "EU-remote"},
+		Bands: map[Zone][]WeightBand{
+			"EU":        {{2000, 800}, {10000, 1500}, {-1, 2500}},
+			"EU-remote": {{2000, 1200}, {5000, 2000}},
+		},
+		Surcharges: Surcharges{
+			FuelBasisPoints: 1000,
+			Remote:          map[Zone]int{"EU-remote": 700},
+			OversizeCm:      45,
+			Oversize:        300,
+		},
+	}
+}
+
+func TestQuoteWith_WeightAndTiersPerCountry(t *testing.T) {
+	strategies := Strategies{
+		ByCountry: map[Country]PricingStrategy{"DE": weightPricing(), "IS": weightPricing()},
+		Default:   TierPricing{Rules: OrderRules{"US": {"mouse": []ItemRule{{0, -1, 550}}}}},
+	}
+	order := Order{
+		"US": {"mouse": 20},
+		"DE": {"laptop": 1, "pillow": 1, "cable": 2},
+		"IS": {"laptop": 1},
+	}
+
+	q, err := QuoteWith(order, strategies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ShipmentQuote{
+		{
+			Country: "DE", Zone: "EU",
+			Items: []ParcelItem{
+				{Item: "laptop", Quantity: 1, ActualGrams: 2500, VolumetricGrams: 1200},
+				{Item: "pillow", Quantity: 1, ActualGrams: 500, VolumetricGrams: 8000, Oversize: true},
+			},
+			ChargeableGrams: 10500,
+			Band:            WeightBand{-1, 2500},
+			Surcharges:      []SurchargeLine{{"oversize x1", 300}, {"fuel", 280}},
+			Subtotal:        3080,
+		},
+		{
+			Country: "IS", Zone: "EU-remote",
+			Items:           []ParcelItem{{Item: "laptop", Quantity: 1, ActualGrams: 2500, VolumetricGrams: 1200}},
+			ChargeableGrams: 2500,
+			Band:            WeightBand{5000, 2000},
+			Surcharges:      []SurchargeLine{{"remote area", 700}, {"fuel", 270}},
+			Subtotal:        2970,
+		},
+	}
+	if !reflect.DeepEqual(q.Shipments, want) {
+		t.Errorf("\nGot:  %+v\nWant: %+v", q.Shipments, want)
+	}
+	if q.Total != 11000+3080+2970 || q.Subtotals["US"] != 11000 {
+		t.Errorf("unexpected totals %d %v", q.Total, q.Subtotals)
+	}
+	if want := []UnpricedItem{{"DE", "cable", 2}}; !reflect.DeepEqual(q.Unpriced, want) {
+		t.Errorf("unpriced: got %v, want %v", q.Unpriced, want)
+	}
+}
+
+func TestWeightPricing_Errors(t *testing.T) {
+	w := weightPricing()
+	if _, err := w.Price("FR", Items{"laptop": 1}); !errors.Is(err, ErrNoZone) {
+		t.Errorf("expected ErrNoZone, got %v", err)
+	}
+	if _, err := w.Price("IS", Items{"laptop": 3}); !errors.Is(err, ErrOverweight) {
+		t.Errorf("expected ErrOverweight, got %v", err)
+	}
+	if _, err := QuoteWith(Order{"US": {"mouse": 1}}, Strategies{}); err == nil {
+		t.Error("expected an error without a strategy")
+	}
+}
+
+func TestValidateWeightBands(t *testing.T) {
+	if err := ValidateWeightBands(weightPricing().Bands); err != nil {
+		t.Errorf("expected valid bands, got %v", err)
+	}
+	bad := map[Zone][]WeightBand{
+		"A": {{-1, 100}, {2000, 200}},
+		"B": {{2000, 100}, {1000, 200}},
+		"C": {{1000, -5}},
+		"D": nil,
+	}
+	if err := ValidateWeightBands(bad); err == nil || len(err.(interface{ Unwrap() []error }).Unwrap()) != 4 {
+		t.Errorf("expected 4 errors, got %v", err)
+	}
+}
+
+func TestWeightPricing_UnsortedBands(t *testing.T) {
+	w := weightPricing()
+	// a 2500 g laptop would match the 10000 g band first
+	w.Bands["EU"] = []WeightBand{{10000, 1500}, {2000, 800}, {-1, 2500}}
+	if _, err := w.Price("DE", Items{"laptop": 1}); !errors.Is(err, ErrBadBands) {
+		t.Errorf("expected ErrBadBands, got %v", err)
+	}
+	if _, err := w.Price("IS", Items{"laptop": 1}); err != nil {
+		t.Errorf("expected the valid EU-remote bands to price, got %v", err)
+	}
+}
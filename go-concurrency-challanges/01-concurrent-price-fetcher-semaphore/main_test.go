@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"concurrency-challanges/pricefetch"
 )
 
 func Test(t *testing.T) {
@@ -143,6 +146,23 @@ Pass
 	}
 }
 
+func TestFetchAll_DuplicateIDs(t *testing.T) {
+	var calls atomic.Int32
+	source := pricefetch.PriceSourceFunc(func(ctx context.Context, id string) (int, error) {
+		calls.Add(1)
+		return mockFetch(ctx, id)
+	})
+
+	result, err := fetchAll(context.Background(), source, []string{"p1", "p2", "p1", "p1", "p2"}, 3)
+	expected := map[string]int{"p1": priceOf("p1"), "p2": priceOf("p2")}
+	if err != nil || !mapsEqual(expected, result) {
+		t.Errorf("expected map %v, got %v (%v)", expected, result, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected one fetch per ID, got %d", n)
+	}
+}
+
 // Helper: deterministic expected price calculation (must mirror mockFetch success path)
 func priceOf(id string) int {
 	sum := 0
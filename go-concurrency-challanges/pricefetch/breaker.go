@@ -0,0 +1,230 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	Closed   BreakerState = iota // calls go through
+	Open                         // calls fail with ErrOpen
+	HalfOpen                     // a few probe calls go through
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+var ErrOpen = errors.New("circuit open")
+
+// Breaker stops calling a failing upstream. It opens after
+// ConsecutiveFailures failures in a row, or when FailureRate of the calls
+// in the last Window failed. After CoolDown it lets HalfOpenProbes calls
+// through: if they all succeed it closes, if one fails it opens again.
+type Breaker struct {
+	ConsecutiveFailures int              // 0 disables
+	FailureRate         float64          // 0 disables
+	Window              time.Duration    // of FailureRate, which needs one
+	MinCalls            int              // in Window before FailureRate applies, 1 when < 1
+	CoolDown            time.Duration    // open for this long before probing
+	HalfOpenProbes      int              // 1 when < 1
+	IsFailure           func(error) bool // any error but a cancelled or expired context when nil
+	OnStateChange       func(from, to BreakerState)
+	Clock               Clock // real time when nil
+
+	mu          sync.Mutex
+	state       BreakerState
+	consecutive int
+	outcomes    []outcome // in Window, oldest first
+	openedAt    time.Time
+	generation  int // of the state, so late probes of an earlier one are ignored
+	probing     int // probes in flight
+	probed      int // probes that succeeded
+}
+
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// --- main logic ---
+
+// Do calls fetch unless the breaker is open, and records how it went.
+func (b *Breaker) Do(ctx context.Context, fetch func(context.Context) (int, error)) (int, error) {
+	probe, generation, err := b.allow()
+	if err != nil {
+		return 0, err
+	}
+	price, err := fetch(ctx)
+	b.record(probe, generation, err)
+	return price, err
+}
+
+// Wrap guards fetch with b.
+func (b *Breaker) Wrap(fetch fetchFunc) fetchFunc {
+	return func(ctx context.Context, id string) (int, error) {
+		return b.Do(ctx, func(ctx context.Context) (int, error) { return fetch(ctx, id) })
+	}
+}
+
+// State returns the current state, moving an open breaker whose CoolDown
+// has passed to half-open the same way a call would.
+func (b *Breaker) State() BreakerState {
+	var changes []BreakerState
+	defer func() { b.notify(changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.coolDown(b.clock().Now(), &changes)
+	return b.state
+}
+
+// --- helpers ---
+
+// allow reports whether a call may go through, and whether it is a probe
+// of the half-open state generation.
+func (b *Breaker) allow() (probe bool, generation int, err error) {
+	var changes []BreakerState
+	defer func() { b.notify(changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.coolDown(b.clock().Now(), &changes)
+	switch b.state {
+	case Open:
+		return false, 0, ErrOpen
+	case HalfOpen:
+		if b.probing+b.probed >= max(b.HalfOpenProbes, 1) {
+			return false, 0, ErrOpen
+		}
+		b.probing++
+		return true, b.generation, nil
+	}
+	return false, 0, nil
+}
+
+func (b *Breaker) record(probe bool, generation int, err error) {
+	var changes []BreakerState
+	defer func() { b.notify(changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock().Now()
+	counts := err == nil || b.isFailure(err)
+	failed := err != nil && counts
+
+	if probe {
+		if generation != b.generation {
+			return // another probe decided already
+		}
+		b.probing--
+		switch {
+		case failed:
+			b.open(now, &changes)
+		case counts:
+			b.probed++
+			if b.probed >= max(b.HalfOpenProbes, 1) {
+				b.set(Closed, &changes)
+			}
+		}
+		return
+	}
+	if b.state != Closed || !counts {
+		return
+	}
+
+	if failed {
+		b.consecutive++
+	} else {
+		b.consecutive = 0
+	}
+	b.outcomes = append(b.outcomes, outcome{now, failed})
+	b.prune(now)
+
+	if b.ConsecutiveFailures > 0 && b.consecutive >= b.ConsecutiveFailures {
+		b.open(now, &changes)
+		return
+	}
+	if b.FailureRate > 0 && b.Window > 0 && len(b.outcomes) >= max(b.MinCalls, 1) {
+		failures := 0
+		for _, o := range b.outcomes {
+			if o.failed {
+				failures++
+			}
+		}
+		if float64(failures)/float64(len(b.outcomes)) >= b.FailureRate {
+			b.open(now, &changes)
+		}
+	}
+}
+
+// coolDown moves an open breaker to half-open once CoolDown has passed.
+func (b *Breaker) coolDown(now time.Time, changes *[]BreakerState) {
+	if b.state == Open && !now.Before(b.openedAt.Add(b.CoolDown)) {
+		b.set(HalfOpen, changes)
+	}
+}
+
+func (b *Breaker) open(now time.Time, changes *[]BreakerState) {
+	b.openedAt = now
+	b.set(Open, changes)
+}
+
+// set changes the state and resets what the new state counts; the hook
+// is called by notify once b.mu is released.
+func (b *Breaker) set(to BreakerState, changes *[]BreakerState) {
+	if changes != nil {
+		*changes = append(*changes, b.state, to)
+	}
+	b.state = to
+	b.generation++
+	b.consecutive, b.outcomes = 0, nil
+	b.probing, b.probed = 0, 0
+}
+
+func (b *Breaker) notify(changes []BreakerState) {
+	if b.OnStateChange == nil {
+		return
+	}
+	for i := 0; i+1 < len(changes); i += 2 {
+		b.OnStateChange(changes[i], changes[i+1])
+	}
+}
+
+func (b *Breaker) prune(now time.Time) {
+	if b.Window <= 0 {
+		b.outcomes = nil
+		return
+	}
+	i := 0
+	for i < len(b.outcomes) && now.Sub(b.outcomes[i].at) >= b.Window {
+		i++
+	}
+	b.outcomes = b.outcomes[i:]
+}
+
+func (b *Breaker) isFailure(err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+	return !isContextErr(err)
+}
+
+func (b *Breaker) clock() Clock {
+	if b.Clock != nil {
+		return b.Clock
+	}
+	return realClock{}
+}
@@ -0,0 +1,271 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	remote := errors.New("remote error")
+	// a call, after advance, that fails with err unless it is nil
+	type call struct {
+		advance       time.Duration
+		err           error
+		stateOnly     bool // only State is read, nothing is fetched
+		expectedOpen  bool // rejected with ErrOpen, fetch not called
+		expectedState BreakerState
+	}
+	type testCase struct {
+		name            string
+		breaker         *Breaker
+		calls           []call
+		expectedChanges []string
+	}
+
+	testCases := []testCase{
+		{
+			name:    "trips, probes after the cool-down and closes",
+			breaker: &Breaker{ConsecutiveFailures: 2, CoolDown: time.Minute, HalfOpenProbes: 2},
+			calls: []call{
+				{err: remote, expectedState: Closed},
+				{err: remote, expectedState: Open},
+				{expectedOpen: true, expectedState: Open},
+				{advance: 59 * time.Second, expectedOpen: true, expectedState: Open},
+				{advance: time.Second, expectedState: HalfOpen},
+				{expectedState: Closed},
+				{err: remote, expectedState: Closed},
+			},
+			expectedChanges: []string{"closed->open", "open->half-open", "half-open->closed"},
+		},
+		{
+			name:    "reading the state after the cool-down reports half-open",
+			breaker: &Breaker{ConsecutiveFailures: 1, CoolDown: time.Minute},
+			calls: []call{
+				{err: remote, expectedState: Open},
+				{advance: time.Minute, stateOnly: true, expectedState: HalfOpen},
+				{expectedState: Closed},
+			},
+			expectedChanges: []string{"closed->open", "open->half-open", "half-open->closed"},
+		},
+		{
+			name:    "a failed probe opens it again",
+			breaker: &Breaker{ConsecutiveFailures: 1, CoolDown: time.Minute, HalfOpenProbes: 3},
+			calls: []call{
+				{err: remote, expectedState: Open},
+				{advance: time.Minute, expectedState: HalfOpen},
+				{err: remote, expectedState: Open},
+				{advance: 30 * time.Second, expectedOpen: true, expectedState: Open},
+				{advance: 30 * time.Second, expectedState: HalfOpen},
+			},
+			expectedChanges: []string{"closed->open", "open->half-open", "half-open->open", "open->half-open"},
+		},
+		{
+			name:    "a success resets the failures in a row",
+			breaker: &Breaker{ConsecutiveFailures: 2, CoolDown: time.Minute},
+			calls: []call{
+				{err: remote, expectedState: Closed},
+				{expectedState: Closed},
+				{err: remote, expectedState: Closed},
+				{expectedState: Closed},
+			},
+		},
+		{
+			name:    "trips on the failure rate once there are enough calls",
+			breaker: &Breaker{FailureRate: 0.5, Window: 10 * time.Second, MinCalls: 4, CoolDown: time.Minute},
+			calls: []call{
+				{err: remote, expectedState: Closed},
+				{err: remote, expectedState: Closed},
+				{expectedState: Closed},
+				{expectedState: Open}, // 2 of 4
+			},
+			expectedChanges: []string{"closed->open"},
+		},
+		{
+			name:    "failures out of the window do not count",
+			breaker: &Breaker{FailureRate: 0.5, Window: 10 * time.Second, MinCalls: 3, CoolDown: time.Minute},
+			calls: []call{
+				{err: remote, expectedState: Closed},
+				{err: remote, expectedState: Closed},
+				{advance: 10 * time.Second, expectedState: Closed},
+				{expectedState: Closed},
+				{err: remote, expectedState: Closed}, // 1 of 3
+				{err: remote, expectedState: Open},   // 2 of 4
+			},
+			expectedChanges: []string{"closed->open"},
+		},
+		{
+			name:    "a cancelled context is not a failure",
+			breaker: &Breaker{ConsecutiveFailures: 1, CoolDown: time.Minute},
+			calls: []call{
+				{err: context.Canceled, expectedState: Closed},
+				{err: fmt.Errorf("fetch: %w", context.DeadlineExceeded), expectedState: Closed},
+				{err: remote, expectedState: Open},
+			},
+			expectedChanges: []string{"closed->open"},
+		},
+		{
+			name: "IsFailure picks what counts",
+			breaker: &Breaker{
+				ConsecutiveFailures: 1,
+				CoolDown:            time.Minute,
+				IsFailure:           func(err error) bool { return errors.Is(err, ErrUnavailable) },
+			},
+			calls: []call{
+				{err: ErrUnknownID, expectedState: Closed},
+				{err: ErrUnavailable, expectedState: Open},
+			},
+			expectedChanges: []string{"closed->open"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			var changes []string
+			b := tc.breaker
+			b.Clock = clock
+			b.OnStateChange = func(from, to BreakerState) { changes = append(changes, from.String()+"->"+to.String()) }
+
+			for i, c := range tc.calls {
+				clock.Advance(c.advance)
+				if c.stateOnly {
+					if state := b.State(); state != c.expectedState {
+						t.Errorf("call %d: expected state %v, got %v", i, c.expectedState, state)
+					}
+					continue
+				}
+				called := false
+				_, err := b.Do(context.Background(), func(context.Context) (int, error) {
+					called = true
+					return 42, c.err
+				})
+				if c.expectedOpen {
+					if !errors.Is(err, ErrOpen) || called {
+						t.Errorf("call %d: expected ErrOpen without a fetch, got %v (fetched: %v)", i, err, called)
+					}
+				} else if !called || !errors.Is(err, c.err) {
+					t.Errorf("call %d: expected a fetch returning %v, got %v (fetched: %v)", i, c.err, err, called)
+				}
+				if state := b.State(); state != c.expectedState {
+					t.Errorf("call %d: expected state %v, got %v", i, c.expectedState, state)
+				}
+			}
+			if !slices.Equal(tc.expectedChanges, changes) {
+				t.Errorf("expected changes %v, got %v", tc.expectedChanges, changes)
+			}
+		})
+	}
+}
+
+func TestBreaker_HalfOpenProbeLimit(t *testing.T) {
+	clock := newFakeClock()
+	b := &Breaker{ConsecutiveFailures: 1, CoolDown: time.Minute, HalfOpenProbes: 2, Clock: clock}
+	b.Do(context.Background(), func(context.Context) (int, error) { return 0, errors.New("remote error") })
+	clock.Advance(time.Minute)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = b.Do(context.Background(), func(context.Context) (int, error) {
+				started <- struct{}{}
+				<-release
+				return 42, nil
+			})
+		}()
+	}
+	<-started
+	<-started
+
+	// both probes are in flight, so a third call is turned away
+	if _, err := b.Do(context.Background(), func(context.Context) (int, error) { return 42, nil }); !errors.Is(err, ErrOpen) {
+		t.Errorf("expected ErrOpen past the probe limit, got %v", err)
+	}
+	close(release)
+	wg.Wait()
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("expected the probes to succeed, got %v", errs)
+	}
+	if state := b.State(); state != Closed {
+		t.Errorf("expected closed after the probes, got %v", state)
+	}
+}
+
+func TestFetcher_Breaker(t *testing.T) {
+	ids := []string{"bad:1", "bad:2", "bad:3", "bad:4", "bad:5", "bad:6"}
+	for _, strategy := range []Strategy{Semaphore{}, WorkerPool{}} {
+		t.Run(fmt.Sprintf("%T", strategy), func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				calls int
+			)
+			source := PriceSourceFunc(func(ctx context.Context, id string) (int, error) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				return mockFetch(ctx, id)
+			})
+			f := &Fetcher{
+				Source:        source,
+				MaxConcurrent: 1,
+				Strategy:      strategy,
+				Policy:        BestEffort,
+				Breaker:       &Breaker{ConsecutiveFailures: 3, CoolDown: time.Minute, Clock: newFakeClock()},
+			}
+
+			_, failed, err := f.FetchAllPartial(context.Background(), ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			open := 0
+			for _, err := range failed {
+				if errors.Is(err, ErrOpen) {
+					open++
+				}
+			}
+			if len(failed) != len(ids) || calls != 3 || open != len(ids)-3 {
+				t.Errorf("expected 3 fetches and %d rejected, got %d fetches and %d rejected of %v", len(ids)-3, calls, open, failed)
+			}
+		})
+	}
+}
+
+func TestFetcher_BreakerIgnoresLimiters(t *testing.T) {
+	clock := newFakeClock()
+	breaker := &Breaker{ConsecutiveFailures: 1, CoolDown: time.Minute, Clock: clock}
+	f := &Fetcher{
+		Source:        PriceSourceFunc(mockFetch),
+		MaxConcurrent: 1,
+		Policy:        BestEffort,
+		Limiters:      []Limiter{&TokenBucket{Rate: 1, Burst: 1, Clock: clock}},
+		Breaker:       breaker,
+	}
+	// one token, and the next one would come after the deadline
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(500*time.Millisecond))
+	defer cancel()
+
+	result, failed, err := f.FetchAllPartial(ctx, []string{"p1", "p2", "p3", "p4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || len(failed) != 3 {
+		t.Fatalf("expected 1 price and 3 failures, got %v and %v", result, failed)
+	}
+	for id, err := range failed {
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("%s: expected ErrRateLimited, got %v", id, err)
+		}
+	}
+	if state := breaker.State(); state != Closed {
+		t.Errorf("expected the breaker to stay closed, got %v", state)
+	}
+}
@@ -0,0 +1,156 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CachedSource puts a cache in front of Source and coalesces concurrent
+// fetches of an ID into one. A price is fresh for TTL. After that, for
+// Stale more, it is still returned at once while a single background
+// fetch refreshes it. Errors are never cached. With a zero TTL and Stale,
+// it only coalesces. Caching is opt-in: give a Fetcher a CachedSource as
+// its Source.
+type CachedSource struct {
+	Source         PriceSource
+	TTL            time.Duration
+	Stale          time.Duration
+	RefreshTimeout time.Duration // of background refreshes, 10s when 0
+	Clock          Clock         // real time when nil
+
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	inflight map[string]*flight
+	metrics  CacheMetrics
+}
+
+// CacheMetrics counts how CachedSource answered.
+type CacheMetrics struct {
+	Hits          int // fresh from the cache
+	StaleHits     int // stale from the cache, refreshing
+	Misses        int // fetched from Source
+	Coalesced     int // waited for a fetch already in flight
+	Refreshes     int // background fetches started
+	RefreshErrors int // background fetches that failed
+}
+
+type cacheEntry struct {
+	price   int
+	fetched time.Time
+}
+
+type flight struct {
+	done  chan struct{}
+	price int
+	err   error
+}
+
+func NewCachedSource(source PriceSource, ttl, stale time.Duration) *CachedSource {
+	return &CachedSource{Source: source, TTL: ttl, Stale: stale}
+}
+
+// --- main logic ---
+
+func (c *CachedSource) Price(ctx context.Context, id string) (int, error) {
+	for {
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries, c.inflight = map[string]cacheEntry{}, map[string]*flight{}
+		}
+
+		if e, ok := c.entries[id]; ok {
+			age := c.clock().Now().Sub(e.fetched)
+			switch {
+			case age < c.TTL:
+				c.metrics.Hits++
+				c.mu.Unlock()
+				return e.price, nil
+			case age < c.TTL+c.Stale:
+				c.metrics.StaleHits++
+				if _, busy := c.inflight[id]; !busy {
+					c.metrics.Refreshes++
+					f := c.start(id)
+					go func() {
+						ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.refreshTimeout())
+						defer cancel()
+						c.fetch(ctx, id, f, true)
+					}()
+				}
+				c.mu.Unlock()
+				return e.price, nil
+			default:
+				delete(c.entries, id)
+			}
+		}
+
+		if f, ok := c.inflight[id]; ok {
+			c.metrics.Coalesced++
+			c.mu.Unlock()
+			select {
+			case <-f.done:
+				if isContextErr(f.err) && ctx.Err() == nil {
+					continue // the caller that fetched gave up, not the source
+				}
+				return f.price, f.err
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+
+		c.metrics.Misses++
+		f := c.start(id)
+		c.mu.Unlock()
+		c.fetch(ctx, id, f, false)
+		return f.price, f.err
+	}
+}
+
+func (c *CachedSource) Metrics() CacheMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metrics
+}
+
+// --- helpers ---
+
+// start registers a fetch of id; c.mu must be held.
+func (c *CachedSource) start(id string) *flight {
+	f := &flight{done: make(chan struct{})}
+	c.inflight[id] = f
+	return f
+}
+
+func (c *CachedSource) fetch(ctx context.Context, id string, f *flight, refresh bool) {
+	price, err := c.Source.Price(ctx, id)
+
+	c.mu.Lock()
+	f.price, f.err = price, err
+	if err == nil {
+		c.entries[id] = cacheEntry{price, c.clock().Now()}
+	} else if refresh {
+		c.metrics.RefreshErrors++
+	}
+	delete(c.inflight, id)
+	c.mu.Unlock()
+	close(f.done)
+}
+
+func (c *CachedSource) refreshTimeout() time.Duration {
+	if c.RefreshTimeout > 0 {
+		return c.RefreshTimeout
+	}
+	return 10 * time.Second
+}
+
+func (c *CachedSource) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return realClock{}
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,262 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingSource counts the fetches of every ID. While gate is open
+// (non-nil and not closed), fetches wait for it. IDs starting with
+// "once:" fail after their first fetch.
+type countingSource struct {
+	mu    sync.Mutex
+	calls map[string]int
+	gate  chan struct{}
+}
+
+func (s *countingSource) Price(ctx context.Context, id string) (int, error) {
+	s.mu.Lock()
+	s.calls[id]++
+	n := s.calls[id]
+	gate := s.gate
+	s.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	if strings.HasPrefix(id, "once:") && n > 1 {
+		return 0, errors.New("remote error")
+	}
+	return mockFetch(ctx, id)
+}
+
+func (s *countingSource) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, n := range s.calls {
+		total += n
+	}
+	return total
+}
+
+func (c *CachedSource) inFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.inflight)
+}
+
+// waitFor polls cond for up to a second, for background refreshes.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCachedSource(t *testing.T) {
+	type env struct {
+		cache  *CachedSource
+		clock  *fakeClock
+		source *countingSource
+	}
+	price := func(e env, id string) error {
+		_, err := e.cache.Price(context.Background(), id)
+		return err
+	}
+	refreshed := func(e env, calls int) {
+		waitFor(func() bool {
+			return e.source.Calls() == calls && e.cache.Metrics().Refreshes > 0 && e.cache.inFlight() == 0
+		})
+	}
+
+	type testCase struct {
+		name            string
+		run             func(e env)
+		expectedFetches int
+		expectedMetrics CacheMetrics
+	}
+
+	testCases := []testCase{
+		{
+			name: "fresh hit",
+			run: func(e env) {
+				price(e, "p1")
+				e.clock.Advance(9 * time.Second)
+				price(e, "p1")
+			},
+			expectedFetches: 1,
+			expectedMetrics: CacheMetrics{Hits: 1, Misses: 1},
+		},
+		{
+			name: "stale served while refreshing",
+			run: func(e env) {
+				price(e, "p1")
+				e.clock.Advance(15 * time.Second)
+				price(e, "p1")
+				refreshed(e, 2)
+				price(e, "p1")
+			},
+			expectedFetches: 2,
+			expectedMetrics: CacheMetrics{Hits: 1, StaleHits: 1, Misses: 1, Refreshes: 1},
+		},
+		{
+			name: "expired",
+			run: func(e env) {
+				price(e, "p1")
+				e.clock.Advance(20 * time.Second)
+				price(e, "p1")
+			},
+			expectedFetches: 2,
+			expectedMetrics: CacheMetrics{Misses: 2},
+		},
+		{
+			name: "errors are not cached",
+			run: func(e env) {
+				price(e, "bad:x")
+				price(e, "bad:x")
+			},
+			expectedFetches: 2,
+			expectedMetrics: CacheMetrics{Misses: 2},
+		},
+		{
+			name: "failed refresh keeps the stale price",
+			run: func(e env) {
+				price(e, "once:x")
+				e.clock.Advance(15 * time.Second)
+				price(e, "once:x")
+				refreshed(e, 2)
+				if err := price(e, "once:x"); err != nil {
+					t.Errorf("expected the stale price, got %v", err)
+				}
+				refreshed(e, 3)
+			},
+			expectedFetches: 3,
+			expectedMetrics: CacheMetrics{StaleHits: 2, Misses: 1, Refreshes: 2, RefreshErrors: 2},
+		},
+		{
+			name: "concurrent requests share one fetch",
+			run: func(e env) {
+				e.source.gate = make(chan struct{})
+				var wg sync.WaitGroup
+				for range 5 {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						price(e, "p1")
+					}()
+				}
+				waitFor(func() bool { return e.cache.Metrics().Coalesced == 4 })
+				close(e.source.gate)
+				wg.Wait()
+			},
+			expectedFetches: 1,
+			expectedMetrics: CacheMetrics{Misses: 1, Coalesced: 4},
+		},
+	}
+
+	passCount := 0
+	failCount := 0
+
+	for _, tc := range testCases {
+		e := env{clock: newFakeClock(), source: &countingSource{calls: map[string]int{}}}
+		e.cache = &CachedSource{Source: e.source, TTL: 10 * time.Second, Stale: 10 * time.Second, Clock: e.clock}
+		tc.run(e)
+
+		fetches, metrics := e.source.Calls(), e.cache.Metrics()
+		if fetches != tc.expectedFetches || metrics != tc.expectedMetrics {
+			failCount++
+			t.Errorf(`---------------------------------
+Case: %s
+
+Expected:
+  Fetches: %d
+  Metrics: %+v
+
+Actual:
+  Fetches: %d
+  Metrics: %+v
+Fail
+`, tc.name, tc.expectedFetches, tc.expectedMetrics, fetches, metrics)
+		} else {
+			passCount++
+			fmt.Printf(`---------------------------------
+Case: %s
+
+Expected:
+  Fetches: %d
+  Metrics: %+v
+
+Actual:
+  Fetches: %d
+  Metrics: %+v
+Pass
+`, tc.name, tc.expectedFetches, tc.expectedMetrics, fetches, metrics)
+		}
+	}
+
+	fmt.Println("---------------------------------")
+	fmt.Printf("%d passed, %d failed\n", passCount, failCount)
+}
+
+func TestFetcher_FetchesDuplicatesOnce(t *testing.T) {
+	ids := []string{"p1", "p1", "p2", "p1"}
+	for _, strategy := range []Strategy{Semaphore{}, WorkerPool{}} {
+		source := &countingSource{calls: map[string]int{}}
+		f := &Fetcher{Source: source, MaxConcurrent: 4, Strategy: strategy}
+
+		result, err := f.FetchAll(context.Background(), ids)
+		if expected := map[string]int{"p1": priceOf("p1"), "p2": priceOf("p2")}; err != nil || !mapsEqual(expected, result) {
+			t.Errorf("%T: expected map %v, got %v (%v)", strategy, expected, result, err)
+		}
+		if calls := source.Calls(); calls != 2 {
+			t.Errorf("%T FetchAll: expected one fetch per ID, got %v", strategy, source.calls)
+		}
+
+		yielded := map[string]int{}
+		for id, r := range f.Stream(context.Background(), ids) {
+			if r.Err != nil || r.Price != priceOf(id) {
+				t.Errorf("%T Stream: %s: unexpected %+v", strategy, id, r)
+			}
+			yielded[id]++
+		}
+		if yielded["p1"] != 3 || yielded["p2"] != 1 {
+			t.Errorf("%T Stream: expected p1 three times and p2 once, got %v", strategy, yielded)
+		}
+		if calls := source.Calls(); calls != 4 {
+			t.Errorf("%T Stream: expected one fetch per ID, got %v", strategy, source.calls)
+		}
+	}
+}
+
+func TestFetcher_CoalescesDuplicates(t *testing.T) {
+	for _, strategy := range []Strategy{Semaphore{}, WorkerPool{}} {
+		source := &countingSource{calls: map[string]int{}}
+		f := &Fetcher{Source: NewCachedSource(source, time.Minute, 0), MaxConcurrent: 4, Strategy: strategy}
+		ids := []string{"p1", "p1", "p2", "p1", "p2", "p3"}
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := f.FetchAll(context.Background(), ids)
+				expected := map[string]int{"p1": priceOf("p1"), "p2": priceOf("p2"), "p3": priceOf("p3")}
+				if err != nil || !mapsEqual(expected, result) {
+					t.Errorf("%T: expected map %v, got %v (%v)", strategy, expected, result, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if calls := source.Calls(); calls != 3 {
+			t.Errorf("%T: expected one fetch per ID, got %v", strategy, source.calls)
+		}
+	}
+}
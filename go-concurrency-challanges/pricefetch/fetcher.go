@@ -0,0 +1,142 @@
+// Package pricefetch fetches many prices concurrently from a PriceSource,
+// with a choice of scheduling Strategy, error policies, retries, limiters,
+// caching and a circuit breaker. Pool, the resizable worker pool that
+// WorkerPool runs on, takes jobs of any type.
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PriceSource fetches the price of one ID.
+type PriceSource interface {
+	Price(ctx context.Context, id string) (int, error)
+}
+
+// PriceSourceFunc adapts a function to PriceSource.
+type PriceSourceFunc func(ctx context.Context, id string) (int, error)
+
+func (f PriceSourceFunc) Price(ctx context.Context, id string) (int, error) {
+	return f(ctx, id)
+}
+
+// Fetcher fetches many prices from Source, at most MaxConcurrent at a
+// time, scheduled by Strategy.
+type Fetcher struct {
+	Source        PriceSource
+	MaxConcurrent int         // 1 when < 1
+	Strategy      Strategy    // Semaphore when nil
+	Policy        ErrorPolicy // for FetchAllPartial; FetchAll always fails fast
+	Retry         RetryPolicy // one attempt when zero
+	Limiters      []Limiter   // waited on in order before every attempt; put KeyLimits before a TokenBucket
+	Breaker       *Breaker    // guards every call to Source, none when nil
+}
+
+// --- main logic ---
+
+// FetchAll returns every price, or the first error after cancelling the
+// fetches still running.
+func (f *Fetcher) FetchAll(ctx context.Context, ids []string) (map[string]int, error) {
+	failFast := *f
+	failFast.Policy = FailFast
+	results, _, err := failFast.FetchAllPartial(ctx, ids)
+	if err != nil {
+		var fe *FetchError
+		if errors.As(err, &fe) {
+			return nil, fe
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+// FetchAllPartial keeps every price it got. It returns the prices, the
+// error of each failed ID, and a non-nil error when the run stopped
+// early: the parent context ended, or Policy gave up, wrapping
+// ErrTooManyFailures and the error that tripped it. IDs not fetched
+// because of that appear in neither map. An ID listed more than once is
+// fetched once.
+func (f *Fetcher) FetchAllPartial(ctx context.Context, ids []string) (map[string]int, FetchErrors, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	ids, _ = unique(ids)
+
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fetch := f.fetchFunc()
+	results := make(map[string]int, len(ids))
+	failed := FetchErrors{}
+	var (
+		mu      sync.Mutex
+		tripped error
+	)
+
+	f.strategy().Run(childCtx, ids, max(f.MaxConcurrent, 1), func(id string) {
+		price, err := fetch(childCtx, id)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err == nil:
+			results[id] = price
+		case childCtx.Err() != nil && errors.Is(err, childCtx.Err()):
+			// cut short by the policy or the parent, not a failure of id
+		default:
+			failed[id] = err
+			if tripped == nil && f.Policy.MaxErrors > 0 && len(failed) >= f.Policy.MaxErrors {
+				tripped = fmt.Errorf("%w (%d): %w", ErrTooManyFailures, len(failed), &FetchError{ID: id, Err: err})
+				cancel()
+			}
+		}
+	})
+
+	if tripped != nil {
+		return results, failed, tripped
+	}
+	if err := ctx.Err(); err != nil {
+		return results, failed, err
+	}
+	return results, failed, nil
+}
+
+// --- helpers ---
+
+// fetchFunc is Source behind the breaker, then the limiters and retries.
+// The breaker wraps Source alone so that only the upstream can trip it,
+// never a limiter turning a fetch away.
+func (f *Fetcher) fetchFunc() fetchFunc {
+	fetch := fetchFunc(f.Source.Price)
+	if f.Breaker != nil {
+		fetch = f.Breaker.Wrap(fetch)
+	}
+	if len(f.Limiters) > 0 {
+		fetch = limited(fetch, f.Limiters)
+	}
+	return f.Retry.Wrap(fetch)
+}
+
+// unique returns ids without repeats, in first-seen order, and how many
+// times each one is listed.
+func unique(ids []string) ([]string, map[string]int) {
+	count := make(map[string]int, len(ids))
+	var out []string
+	for _, id := range ids {
+		if count[id] == 0 {
+			out = append(out, id)
+		}
+		count[id]++
+	}
+	return out, count
+}
+
+func (f *Fetcher) strategy() Strategy {
+	if f.Strategy != nil {
+		return f.Strategy
+	}
+	return Semaphore{}
+}
@@ -0,0 +1,135 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockFetch prices every id from its bytes, except for these prefixes:
+// bad: fails, flaky: is unavailable and slow: waits for ctx.
+func mockFetch(ctx context.Context, id string) (int, error) {
+	if strings.HasPrefix(id, "bad:") {
+		return 0, errors.New("remote error")
+	}
+	if strings.HasPrefix(id, "flaky:") {
+		return 0, ErrUnavailable
+	}
+	if strings.HasPrefix(id, "slow:") {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	return priceOf(id), nil
+}
+
+// priceOf is the price mockFetch gives id.
+func priceOf(id string) int {
+	sum := 0
+	for _, r := range id {
+		sum += int(r)
+	}
+	return len(id) + (sum % 17)
+}
+
+// mapsEqual compares two string->int maps for exact match
+func mapsEqual(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// gaugeSource records the most fetches it had in flight at once.
+type gaugeSource struct {
+	inFlight, peak atomic.Int32
+}
+
+func (s *gaugeSource) Price(ctx context.Context, id string) (int, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		p := s.peak.Load()
+		if n <= p || s.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return mockFetch(ctx, id)
+}
+
+func TestFetcher_Strategies(t *testing.T) {
+	ids := make([]string, 40)
+	expected := map[string]int{}
+	for i := range ids {
+		ids[i] = fmt.Sprintf("p%d", i)
+		expected[ids[i]] = priceOf(ids[i])
+	}
+
+	for _, strategy := range []Strategy{Semaphore{}, WorkerPool{}} {
+		t.Run(fmt.Sprintf("%T", strategy), func(t *testing.T) {
+			source := &gaugeSource{}
+			f := &Fetcher{Source: source, MaxConcurrent: 3, Strategy: strategy}
+
+			result, err := f.FetchAll(context.Background(), ids)
+			if err != nil || !mapsEqual(expected, result) {
+				t.Errorf("expected map %v, got %v (%v)", expected, result, err)
+			}
+			if peak := source.peak.Load(); peak > 3 {
+				t.Errorf("expected at most 3 fetches in flight, got %d", peak)
+			}
+
+			result, err = f.FetchAll(context.Background(), []string{"p1", "bad:oops", "slow:hang"})
+			var fe *FetchError
+			if result != nil || !errors.As(err, &fe) || fe.ID != "bad:oops" {
+				t.Errorf("expected the failure of bad:oops, got %v (%v)", result, err)
+			}
+		})
+	}
+}
+
+func TestFetcher_PolicyAndRetry(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = map[string]int{}
+	)
+	// fails twice with a transient error, then answers
+	source := PriceSourceFunc(func(ctx context.Context, id string) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[id]++
+		if calls[id] <= 2 {
+			return 0, ErrUnavailable
+		}
+		return mockFetch(ctx, id)
+	})
+	f := &Fetcher{
+		Source:        source,
+		MaxConcurrent: 2,
+		Policy:        BestEffort,
+		Retry:         RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, Clock: newFakeClock(), Jitter: noJitter},
+	}
+
+	result, failed, err := f.FetchAllPartial(context.Background(), []string{"p1", "bad:x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]int{"p1": priceOf("p1")}; !mapsEqual(expected, result) {
+		t.Errorf("expected map %v, got %v", expected, result)
+	}
+	if len(failed) != 1 || failed["bad:x"] == nil {
+		t.Errorf("expected bad:x to fail, got %v", failed)
+	}
+	if calls["p1"] != 3 || calls["bad:x"] != 3 {
+		t.Errorf("expected 3 calls each, got %v", calls)
+	}
+}
@@ -0,0 +1,239 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter is waited on before every fetch attempt. release is called
+// once the attempt is over.
+type Limiter interface {
+	Wait(ctx context.Context, id string) (release func(), err error)
+	Stats() WaitStats
+}
+
+// WaitStats sums up how long fetches waited on a limiter.
+type WaitStats struct {
+	Calls   int           // every Wait, with or without waiting
+	Waited  int           // Waits that had to wait
+	Total   time.Duration // time spent waiting
+	Longest time.Duration
+}
+
+var ErrRateLimited = errors.New("rate limited")
+
+// --- token bucket ---
+
+// TokenBucket allows Rate fetches per second on average and bursts of up
+// to Burst. Every Wait takes a token, waiting for it when the bucket is
+// empty, or fails with ErrRateLimited when the token would come after the
+// context deadline.
+type TokenBucket struct {
+	Rate  float64 // tokens per second, no limit when <= 0
+	Burst int     // bucket size, 1 when < 1
+	Clock Clock   // real time when nil
+
+	mu     sync.Mutex
+	tokens float64 // negative when waits are already promised
+	last   time.Time
+	stats  WaitStats
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{Rate: rate, Burst: burst}
+}
+
+func (b *TokenBucket) Wait(ctx context.Context, id string) (func(), error) {
+	clock := b.clock()
+	b.mu.Lock()
+	if b.Rate <= 0 {
+		b.stats.record(0)
+		b.mu.Unlock()
+		return func() {}, nil
+	}
+	now := clock.Now()
+	burst := float64(max(b.Burst, 1))
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*b.Rate)
+	}
+	b.last = now
+
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.Rate * float64(time.Second))
+	}
+	if deadline, ok := ctx.Deadline(); ok && wait > 0 && now.Add(wait).After(deadline) {
+		b.stats.Calls++
+		b.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s would wait %v, past the deadline", ErrRateLimited, id, wait)
+	}
+	b.tokens-- // reserved, even if the token only arrives after wait
+	b.stats.record(wait)
+	b.mu.Unlock()
+
+	if wait > 0 {
+		select {
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.tokens++ // give the reservation back
+			b.mu.Unlock()
+			return nil, ctx.Err()
+		case <-clock.After(wait):
+		}
+	}
+	return func() {}, nil
+}
+
+func (b *TokenBucket) Stats() WaitStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+func (b *TokenBucket) clock() Clock {
+	if b.Clock != nil {
+		return b.Clock
+	}
+	return realClock{}
+}
+
+// --- per-key concurrency ---
+
+// KeyLimits caps the fetches in flight per key, like the supplier of an
+// ID, on top of the Fetcher's MaxConcurrent.
+type KeyLimits struct {
+	Key     func(id string) string // PrefixKey when nil
+	Limits  map[string]int         // per key
+	Default int                    // for other keys, no limit when 0
+
+	mu    sync.Mutex
+	sems  map[string]chan struct{}
+	stats map[string]WaitStats
+}
+
+// PrefixKey is the part of id before the first ':', "acme" for
+// "acme:sku-1", or "" when there is none.
+func PrefixKey(id string) string {
+	key, _, ok := strings.Cut(id, ":")
+	if !ok {
+		return ""
+	}
+	return key
+}
+
+func (k *KeyLimits) Wait(ctx context.Context, id string) (func(), error) {
+	key := PrefixKey
+	if k.Key != nil {
+		key = k.Key
+	}
+	name := key(id)
+	sem := k.sem(name)
+	if sem == nil {
+		k.record(name, 0)
+		return func() {}, nil
+	}
+
+	select {
+	case sem <- struct{}{}:
+		k.record(name, 0)
+		return func() { <-sem }, nil
+	default:
+	}
+
+	start := time.Now()
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		k.record(name, time.Since(start))
+		return nil, ctx.Err()
+	}
+	k.record(name, time.Since(start))
+	return func() { <-sem }, nil
+}
+
+// Stats adds up the stats of every key.
+func (k *KeyLimits) Stats() WaitStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var total WaitStats
+	for _, s := range k.stats {
+		total.Calls += s.Calls
+		total.Waited += s.Waited
+		total.Total += s.Total
+		total.Longest = max(total.Longest, s.Longest)
+	}
+	return total
+}
+
+func (k *KeyLimits) StatsByKey() map[string]WaitStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	out := make(map[string]WaitStats, len(k.stats))
+	for key, s := range k.stats {
+		out[key] = s
+	}
+	return out
+}
+
+func (k *KeyLimits) sem(key string) chan struct{} {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if sem, ok := k.sems[key]; ok {
+		return sem
+	}
+	limit, ok := k.Limits[key]
+	if !ok {
+		limit = k.Default
+	}
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+	if k.sems == nil {
+		k.sems = map[string]chan struct{}{}
+	}
+	k.sems[key] = sem
+	return sem
+}
+
+func (k *KeyLimits) record(key string, wait time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.stats == nil {
+		k.stats = map[string]WaitStats{}
+	}
+	s := k.stats[key]
+	s.record(wait)
+	k.stats[key] = s
+}
+
+// --- helpers ---
+
+func (s *WaitStats) record(wait time.Duration) {
+	s.Calls++
+	if wait > 0 {
+		s.Waited++
+		s.Total += wait
+		s.Longest = max(s.Longest, wait)
+	}
+}
+
+// limited waits on every limiter, in order, before each fetch.
+func limited(fetch fetchFunc, limiters []Limiter) fetchFunc {
+	return func(ctx context.Context, id string) (int, error) {
+		for _, l := range limiters {
+			release, err := l.Wait(ctx, id)
+			if err != nil {
+				return 0, err
+			}
+			defer release()
+		}
+		return fetch(ctx, id)
+	}
+}
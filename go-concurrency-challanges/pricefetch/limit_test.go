@@ -0,0 +1,118 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_Wait(t *testing.T) {
+	clock := newFakeClock()
+	b := &TokenBucket{Rate: 10, Burst: 3, Clock: clock}
+
+	for i := 0; i < 6; i++ {
+		if _, err := b.Wait(context.Background(), fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the burst goes through at once, then one token every 100ms
+	expectedWaits := []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}
+	if waits := clock.Waits(); !slices.Equal(waits, expectedWaits) {
+		t.Errorf("expected waits %v, got %v", expectedWaits, waits)
+	}
+	expected := WaitStats{Calls: 6, Waited: 3, Total: 300 * time.Millisecond, Longest: 100 * time.Millisecond}
+	if stats := b.Stats(); stats != expected {
+		t.Errorf("expected stats %+v, got %+v", expected, stats)
+	}
+
+	// an idle second refills the bucket up to the burst only
+	clock.Advance(time.Second)
+	for i := 0; i < 4; i++ {
+		b.Wait(context.Background(), "again")
+	}
+	if waits := clock.Waits(); len(waits) != 4 {
+		t.Errorf("expected one more wait after the burst, got %v", waits)
+	}
+}
+
+func TestTokenBucket_WaitPastDeadline(t *testing.T) {
+	clock := newFakeClock()
+	b := &TokenBucket{Rate: 1, Burst: 1, Clock: clock}
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(500*time.Millisecond))
+	defer cancel()
+
+	if _, err := b.Wait(ctx, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Wait(ctx, "second"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	if len(clock.Waits()) != 0 {
+		t.Errorf("expected no wait, got %v", clock.Waits())
+	}
+}
+
+// keyGauge records the most fetches in flight per key.
+type keyGauge struct {
+	mu             sync.Mutex
+	inFlight, peak map[string]int
+}
+
+func (g *keyGauge) Price(ctx context.Context, id string) (int, error) {
+	key := PrefixKey(id)
+	g.mu.Lock()
+	g.inFlight[key]++
+	g.peak[key] = max(g.peak[key], g.inFlight[key])
+	g.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	g.mu.Lock()
+	g.inFlight[key]--
+	g.mu.Unlock()
+	return mockFetch(ctx, id)
+}
+
+func TestFetcher_Limiters(t *testing.T) {
+	var ids []string
+	expected := map[string]int{}
+	for i := 0; i < 10; i++ {
+		for _, supplier := range []string{"acme", "globex", "initech"} {
+			id := fmt.Sprintf("%s:%d", supplier, i)
+			ids = append(ids, id)
+			expected[id] = priceOf(id)
+		}
+	}
+
+	for _, strategy := range []Strategy{Semaphore{}, WorkerPool{}} {
+		t.Run(fmt.Sprintf("%T", strategy), func(t *testing.T) {
+			source := &keyGauge{inFlight: map[string]int{}, peak: map[string]int{}}
+			keys := &KeyLimits{Limits: map[string]int{"acme": 1, "globex": 2}}
+			bucket := NewTokenBucket(2000, 5)
+			f := &Fetcher{Source: source, MaxConcurrent: 6, Strategy: strategy, Limiters: []Limiter{keys, bucket}}
+
+			result, err := f.FetchAll(context.Background(), ids)
+			if err != nil || !mapsEqual(expected, result) {
+				t.Errorf("expected map %v, got %v (%v)", expected, result, err)
+			}
+			if source.peak["acme"] > 1 || source.peak["globex"] > 2 {
+				t.Errorf("expected at most 1 acme and 2 globex fetches in flight, got %v", source.peak)
+			}
+
+			byKey := keys.StatsByKey()
+			if byKey["acme"].Calls != 10 || byKey["initech"].Waited != 0 {
+				t.Errorf("unexpected stats by key %+v", byKey)
+			}
+			if byKey["acme"].Waited == 0 {
+				t.Errorf("expected acme fetches to queue, got %+v", byKey["acme"])
+			}
+			if stats := bucket.Stats(); stats.Calls != len(ids) || stats.Waited == 0 {
+				t.Errorf("expected every fetch through the bucket and some waits, got %+v", stats)
+			}
+		})
+	}
+}
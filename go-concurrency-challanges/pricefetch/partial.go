@@ -0,0 +1,53 @@
+package pricefetch
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ErrorPolicy decides when Fetcher.FetchAllPartial gives up: after MaxErrors
+// failed IDs, or never when MaxErrors is 0.
+type ErrorPolicy struct {
+	MaxErrors int
+}
+
+var (
+	FailFast   = ErrorPolicy{MaxErrors: 1}
+	BestEffort = ErrorPolicy{}
+)
+
+// FailAfter stops once n IDs have failed.
+func FailAfter(n int) ErrorPolicy {
+	return ErrorPolicy{MaxErrors: max(n, 1)}
+}
+
+var ErrTooManyFailures = errors.New("too many failed fetches")
+
+// FetchError is the failure of one ID.
+type FetchError struct {
+	ID  string
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("fetch failed for %s: %v", e.ID, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// FetchErrors holds the error of every ID that failed.
+type FetchErrors map[string]error
+
+// Err joins the errors as *FetchError, sorted by ID, or returns nil when
+// nothing failed.
+func (e FetchErrors) Err() error {
+	var errs []error
+	for _, id := range slices.Sorted(maps.Keys(e)) {
+		errs = append(errs, &FetchError{ID: id, Err: e[id]})
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFetcher_FetchAllPartial(t *testing.T) {
+	type testCase struct {
+		name          string
+		ids           []string
+		maxConcurrent int
+		policy        ErrorPolicy
+		timeout       time.Duration // parent deadline, none when 0
+		expectedMap   map[string]int
+		expectedFails []string
+		expectedErr   error
+	}
+
+	testCases := []testCase{
+		{
+			name:          "best effort keeps every price",
+			ids:           []string{"p1", "bad:a", "p2", "bad:b"},
+			maxConcurrent: 2,
+			policy:        BestEffort,
+			expectedMap:   map[string]int{"p1": priceOf("p1"), "p2": priceOf("p2")},
+			expectedFails: []string{"bad:a", "bad:b"},
+		},
+		{
+			name:          "fail fast cancels the slow fetch",
+			ids:           []string{"bad:a", "slow:hang"},
+			maxConcurrent: 2,
+			policy:        FailFast,
+			expectedMap:   map[string]int{},
+			expectedFails: []string{"bad:a"},
+			expectedErr:   ErrTooManyFailures,
+		},
+		{
+			name:          "fail after two",
+			ids:           []string{"bad:a", "slow:hang", "bad:b"},
+			maxConcurrent: 3,
+			policy:        FailAfter(2),
+			expectedMap:   map[string]int{},
+			expectedFails: []string{"bad:a", "bad:b"},
+			expectedErr:   ErrTooManyFailures,
+		},
+		{
+			name:          "fail after three never trips on two",
+			ids:           []string{"bad:a", "p1", "bad:b"},
+			maxConcurrent: 1,
+			policy:        FailAfter(3),
+			expectedMap:   map[string]int{"p1": priceOf("p1")},
+			expectedFails: []string{"bad:a", "bad:b"},
+		},
+		{
+			name:          "parent deadline keeps what finished",
+			ids:           []string{"p1", "slow:hang", "p2"},
+			maxConcurrent: 3,
+			policy:        BestEffort,
+			timeout:       20 * time.Millisecond,
+			expectedMap:   map[string]int{"p1": priceOf("p1"), "p2": priceOf("p2")},
+			expectedErr:   context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			f := &Fetcher{Source: PriceSourceFunc(mockFetch), MaxConcurrent: tc.maxConcurrent, Policy: tc.policy, Retry: DefaultRetry}
+			result, failed, err := f.FetchAllPartial(ctx, tc.ids)
+			if !mapsEqual(tc.expectedMap, result) {
+				t.Errorf("expected map %v, got %v", tc.expectedMap, result)
+			}
+			if len(failed) != len(tc.expectedFails) {
+				t.Errorf("expected failures %v, got %v", tc.expectedFails, failed)
+			}
+			for _, id := range tc.expectedFails {
+				if failed[id] == nil {
+					t.Errorf("expected %s to fail, got %v", id, failed)
+				}
+			}
+			if (tc.expectedErr == nil) != (err == nil) || (err != nil && !errors.Is(err, tc.expectedErr)) {
+				t.Errorf("expected error %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestFetchErrors_Err(t *testing.T) {
+	remote := errors.New("remote error")
+	failed := FetchErrors{"bad:b": remote, "bad:a": context.DeadlineExceeded}
+
+	err := failed.Err()
+	if want := "fetch failed for bad:a: context deadline exceeded\nfetch failed for bad:b: remote error"; err.Error() != want {
+		t.Errorf("expected `%s`, got `%s`", want, err)
+	}
+	if !errors.Is(err, remote) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the joined error to wrap every cause")
+	}
+	var fe *FetchError
+	if !errors.As(err, &fe) || fe.ID != "bad:a" {
+		t.Errorf("expected the first *FetchError to be bad:a, got %v", fe)
+	}
+	if err := errors.Join(FetchErrors{}.Err(), nil); err != nil {
+		t.Errorf("expected nil for no failures, got %v", err)
+	}
+}
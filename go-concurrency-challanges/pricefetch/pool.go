@@ -0,0 +1,225 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrPoolClosed = errors.New("pool closed")
+
+// Pool runs jobs submitted over time on a set of workers that can grow or
+// shrink while it runs. Jobs wait in a queue of fixed size; Submit blocks
+// while it is full.
+type Pool[J, R any] struct {
+	work   func(ctx context.Context, job J) R
+	jobs   chan poolJob[J, R]
+	ctx    context.Context // given to every job, cancelled by Stop
+	cancel context.CancelFunc
+
+	submitMu  sync.RWMutex // held for writing only to close jobs
+	closing   chan struct{}
+	closeOnce sync.Once
+	closed    bool
+	wg        sync.WaitGroup
+
+	mu      sync.Mutex
+	target  int
+	running int
+	wake    chan struct{} // closed to make idle workers check target
+	stats   PoolStats
+}
+
+type poolJob[J, R any] struct {
+	job    J
+	result chan R
+}
+
+// PoolStats is a snapshot of a Pool.
+type PoolStats struct {
+	Workers    int // running
+	Busy       int // running a job
+	QueueDepth int // submitted, not started
+	Submitted  int
+	Completed  int
+	Dropped    int           // queued when Stop was called
+	BusyTime   time.Duration // spent in jobs, by all workers
+}
+
+// Utilisation is the share of workers running a job.
+func (s PoolStats) Utilisation() float64 {
+	if s.Workers == 0 {
+		return 0
+	}
+	return float64(s.Busy) / float64(s.Workers)
+}
+
+// NewPool starts workers running work, with room for queueSize jobs
+// waiting.
+func NewPool[J, R any](workers, queueSize int, work func(ctx context.Context, job J) R) *Pool[J, R] {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool[J, R]{
+		work:    work,
+		jobs:    make(chan poolJob[J, R], max(queueSize, 0)),
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+		wake:    make(chan struct{}),
+	}
+	p.Resize(workers)
+	return p
+}
+
+// --- main logic ---
+
+// Submit queues job and returns the channel its result will be sent on.
+// The channel is closed without a result if Stop drops the job.
+func (p *Pool[J, R]) Submit(ctx context.Context, job J) (<-chan R, error) {
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	j := poolJob[J, R]{job: job, result: make(chan R, 1)}
+	p.count(1) // before a worker can complete it
+	select {
+	case p.jobs <- j:
+		return j.result, nil
+	case <-p.closing:
+		p.count(-1)
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		p.count(-1)
+		return nil, ctx.Err()
+	}
+}
+
+// Resize sets the number of workers to n, at least 1. Extra workers stop
+// after the job they are running.
+func (p *Pool[J, R]) Resize(n int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.closing:
+		return ErrPoolClosed
+	default:
+	}
+
+	p.target = max(n, 1)
+	for p.running < p.target {
+		p.running++
+		p.wg.Add(1)
+		go p.worker()
+	}
+	close(p.wake)
+	p.wake = make(chan struct{})
+	return nil
+}
+
+// Shutdown stops taking jobs and waits until the queued ones are done. If
+// ctx ends first, it calls Stop and returns ctx.Err().
+func (p *Pool[J, R]) Shutdown(ctx context.Context) error {
+	p.close()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.Stop()
+		return ctx.Err()
+	}
+}
+
+// Stop stops taking jobs, cancels the context of the running ones and
+// drops the queued ones. It does not wait for running jobs to return.
+func (p *Pool[J, R]) Stop() {
+	p.cancel()
+	p.close()
+}
+
+func (p *Pool[J, R]) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	s.Workers = p.running
+	s.QueueDepth = len(p.jobs)
+	return s
+}
+
+// --- helpers ---
+
+func (p *Pool[J, R]) worker() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		if p.running > p.target {
+			p.running--
+			p.mu.Unlock()
+			return
+		}
+		wake := p.wake
+		p.mu.Unlock()
+
+		select {
+		case j, ok := <-p.jobs:
+			if !ok {
+				p.mu.Lock()
+				p.running--
+				p.mu.Unlock()
+				return
+			}
+			p.run(j)
+		case <-wake:
+		}
+	}
+}
+
+func (p *Pool[J, R]) run(j poolJob[J, R]) {
+	defer close(j.result)
+	if p.ctx.Err() != nil {
+		p.mu.Lock()
+		p.stats.Dropped++
+		p.mu.Unlock()
+		return
+	}
+
+	p.mu.Lock()
+	p.stats.Busy++
+	p.mu.Unlock()
+	start := time.Now()
+
+	r := p.work(p.ctx, j.job)
+
+	p.mu.Lock()
+	p.stats.Busy--
+	p.stats.Completed++
+	p.stats.BusyTime += time.Since(start)
+	p.mu.Unlock()
+	j.result <- r
+}
+
+func (p *Pool[J, R]) count(submitted int) {
+	p.mu.Lock()
+	p.stats.Submitted += submitted
+	p.mu.Unlock()
+}
+
+// close stops Submit and lets the workers exit once the queue is empty.
+func (p *Pool[J, R]) close() {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		close(p.closing) // wakes up blocked Submits, so the lock below is free soon
+		p.mu.Unlock()
+
+		p.submitMu.Lock()
+		p.closed = true
+		close(p.jobs)
+		p.submitMu.Unlock()
+	})
+}
@@ -0,0 +1,152 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// waitUntil polls cond for up to a second.
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func fetchJob(ctx context.Context, id string) Result {
+	price, err := mockFetch(ctx, id)
+	return Result{Price: price, Err: err}
+}
+
+func TestPool_Results(t *testing.T) {
+	checkNoLeaks(t)
+	p := NewPool(3, 4, fetchJob)
+
+	results := map[string]<-chan Result{}
+	for i := range 20 {
+		id := fmt.Sprintf("p%d", i)
+		ch, err := p.Submit(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[id] = ch
+	}
+	for id, ch := range results {
+		if r := <-ch; r.Err != nil || r.Price != priceOf(id) {
+			t.Errorf("%s: expected %d, got %+v", id, priceOf(id), r)
+		}
+	}
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Submit(context.Background(), "late"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed after Shutdown, got %v", err)
+	}
+	if err := p.Resize(5); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed from Resize, got %v", err)
+	}
+	stats := p.Stats()
+	if stats.Submitted != 20 || stats.Completed != 20 || stats.Workers != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPool_Resize(t *testing.T) {
+	checkNoLeaks(t)
+	release := make(chan struct{})
+	p := NewPool(1, 10, func(ctx context.Context, n int) int {
+		<-release
+		return n * 2
+	})
+	defer p.Stop()
+
+	var results []<-chan int
+	for i := range 6 {
+		ch, err := p.Submit(context.Background(), i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, ch)
+	}
+	waitUntil(t, "one busy worker", func() bool { s := p.Stats(); return s.Busy == 1 && s.QueueDepth == 5 })
+	if u := p.Stats().Utilisation(); u != 1 {
+		t.Errorf("expected full utilisation, got %v", u)
+	}
+
+	p.Resize(4)
+	waitUntil(t, "four busy workers", func() bool { s := p.Stats(); return s.Workers == 4 && s.Busy == 4 && s.QueueDepth == 2 })
+
+	p.Resize(2)
+	close(release)
+	for i, ch := range results {
+		if r := <-ch; r != i*2 {
+			t.Errorf("job %d: expected %d, got %d", i, i*2, r)
+		}
+	}
+	waitUntil(t, "two workers", func() bool { s := p.Stats(); return s.Workers == 2 && s.Busy == 0 })
+	if u := p.Stats().Utilisation(); u != 0 {
+		t.Errorf("expected idle workers, got %v", u)
+	}
+}
+
+func TestPool_ShutdownTimeout(t *testing.T) {
+	checkNoLeaks(t)
+	p := NewPool(1, 5, fetchJob)
+
+	hung, _ := p.Submit(context.Background(), "slow:hang")
+	queued, _ := p.Submit(context.Background(), "p1")
+	waitUntil(t, "the slow job to start", func() bool { return p.Stats().Busy == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	if r := <-hung; !errors.Is(r.Err, context.Canceled) {
+		t.Errorf("expected the running job to be cancelled, got %+v", r)
+	}
+	if r, ok := <-queued; ok {
+		t.Errorf("expected the queued job to be dropped, got %+v", r)
+	}
+	waitUntil(t, "workers to exit", func() bool { return p.Stats().Workers == 0 })
+	if dropped := p.Stats().Dropped; dropped != 1 {
+		t.Errorf("expected 1 dropped job, got %d", dropped)
+	}
+}
+
+func TestPool_SubmitWaitsForRoom(t *testing.T) {
+	checkNoLeaks(t)
+	release := make(chan struct{})
+	p := NewPool(1, 1, func(ctx context.Context, n int) int {
+		<-release
+		return n
+	})
+
+	p.Submit(context.Background(), 1)
+	waitUntil(t, "the first job to start", func() bool { return p.Stats().Busy == 1 })
+	p.Submit(context.Background(), 2) // fills the queue
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Submit(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Submit to wait for room until its deadline, got %v", err)
+	}
+
+	blocked := make(chan error)
+	go func() {
+		_, err := p.Submit(context.Background(), 4)
+		blocked <- err
+	}()
+	p.Stop()
+	if err := <-blocked; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected Stop to release a blocked Submit, got %v", err)
+	}
+	close(release)
+}
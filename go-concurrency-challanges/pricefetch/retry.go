@@ -0,0 +1,143 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrUnavailable marks a failure worth retrying, like a 503 from an
+// upstream price API.
+var ErrUnavailable = errors.New("upstream unavailable")
+
+// Clock is the time source of retries, so tests can run without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type fetchFunc func(ctx context.Context, id string) (int, error)
+
+// RetryPolicy retries a fetch with exponential backoff and full jitter:
+// before attempt n+1 it waits a random time between 0 and
+// min(MaxDelay, BaseDelay·2ⁿ⁻¹). It never waits past the context deadline.
+type RetryPolicy struct {
+	MaxAttempts int                               // including the first, 1 when < 1
+	BaseDelay   time.Duration                     // backoff cap after the first attempt
+	MaxDelay    time.Duration                     // backoff cap overall, none when 0
+	Retryable   func(error) bool                  // IsRetryable when nil
+	Clock       Clock                             // real time when nil
+	Jitter      func(time.Duration) time.Duration // uniform in [0, d] when nil
+}
+
+var (
+	NoRetry      = RetryPolicy{MaxAttempts: 1}
+	DefaultRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second}
+)
+
+// IsRetryable accepts ErrUnavailable and timeouts, and never a cancelled
+// or expired context.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return errors.Is(err, ErrUnavailable)
+}
+
+// --- main logic ---
+
+// Do calls fetch until it succeeds, fails with an error that is not
+// retryable, runs out of attempts, or the next wait would outlast ctx.
+func (p RetryPolicy) Do(ctx context.Context, fetch func(context.Context) (int, error)) (int, error) {
+	attempts := max(p.MaxAttempts, 1)
+	clock := p.clock()
+	for attempt := 1; ; attempt++ {
+		price, err := fetch(ctx)
+		if err == nil {
+			return price, nil
+		}
+		if attempt == attempts || !p.retryable(err) {
+			return 0, wrapAttempts(attempt, err)
+		}
+
+		delay := p.jitter(p.backoff(attempt))
+		if deadline, ok := ctx.Deadline(); ok && clock.Now().Add(delay).After(deadline) {
+			return 0, wrapAttempts(attempt, err) // would wake up too late
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("retry after %d attempts: %w (last error: %v)", attempt, ctx.Err(), err)
+		case <-clock.After(delay):
+		}
+	}
+}
+
+// Wrap retries fetch with p.
+func (p RetryPolicy) Wrap(fetch fetchFunc) fetchFunc {
+	return func(ctx context.Context, id string) (int, error) {
+		return p.Do(ctx, func(ctx context.Context) (int, error) { return fetch(ctx, id) })
+	}
+}
+
+// --- helpers ---
+
+// backoff is the cap of the wait after attempt, BaseDelay·2^(attempt-1)
+// up to MaxDelay.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+		if d > time.Duration(1<<62) {
+			break // doubling would overflow
+		}
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func (p RetryPolicy) jitter(d time.Duration) time.Duration {
+	if p.Jitter != nil {
+		return p.Jitter(d)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+func (p RetryPolicy) clock() Clock {
+	if p.Clock != nil {
+		return p.Clock
+	}
+	return realClock{}
+}
+
+func wrapAttempts(attempts int, err error) error {
+	if attempts == 1 {
+		return err
+	}
+	return fmt.Errorf("after %d attempts: %w", attempts, err)
+}
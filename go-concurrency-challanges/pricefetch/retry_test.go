@@ -0,0 +1,245 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock moves forward by every wait instead of sleeping. With block
+// set, waits never end.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+	block bool
+}
+
+func newFakeClock() *fakeClock { return &fakeClock{now: time.Now()} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if !c.block {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch
+}
+
+// Advance moves the clock without a wait, like time passing between calls.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.waits)
+}
+
+func noJitter(d time.Duration) time.Duration { return d }
+
+// failing fails with errs in order, then returns 42.
+func failing(errs ...error) (func(context.Context) (int, error), *int) {
+	calls := 0
+	return func(context.Context) (int, error) {
+		calls++
+		if calls <= len(errs) {
+			return 0, errs[calls-1]
+		}
+		return 42, nil
+	}, &calls
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	remote := errors.New("remote error")
+	type testCase struct {
+		name          string
+		policy        RetryPolicy
+		errs          []error
+		timeout       time.Duration // parent deadline, none when 0
+		expected      int
+		expectedErr   string
+		expectedCalls int
+		expectedWaits []time.Duration
+	}
+
+	testCases := []testCase{
+		{
+			name:          "succeeds on the third attempt",
+			policy:        RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second},
+			errs:          []error{ErrUnavailable, ErrUnavailable},
+			expected:      42,
+			expectedCalls: 3,
+			expectedWaits: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:          "backoff stops growing at MaxDelay",
+			policy:        RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 3 * time.Second},
+			errs:          []error{ErrUnavailable, ErrUnavailable, ErrUnavailable, ErrUnavailable, ErrUnavailable},
+			expectedErr:   "after 5 attempts: upstream unavailable",
+			expectedCalls: 5,
+			expectedWaits: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+		{
+			name:          "gives up on an error that is not retryable",
+			policy:        RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second},
+			errs:          []error{ErrUnavailable, remote},
+			expectedErr:   "after 2 attempts: remote error",
+			expectedCalls: 2,
+			expectedWaits: []time.Duration{time.Second},
+		},
+		{
+			name:          "custom classifier",
+			policy:        RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, Retryable: func(err error) bool { return err == remote }},
+			errs:          []error{remote},
+			expected:      42,
+			expectedCalls: 2,
+			expectedWaits: []time.Duration{time.Second},
+		},
+		{
+			name:          "no wait outlasts the deadline",
+			policy:        RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Second},
+			errs:          []error{ErrUnavailable, ErrUnavailable, ErrUnavailable},
+			timeout:       25 * time.Second,
+			expectedErr:   "after 2 attempts: upstream unavailable",
+			expectedCalls: 2,
+			expectedWaits: []time.Duration{10 * time.Second},
+		},
+		{
+			name:          "one attempt",
+			policy:        NoRetry,
+			errs:          []error{ErrUnavailable},
+			expectedErr:   "upstream unavailable",
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			clock := newFakeClock()
+			tc.policy.Clock, tc.policy.Jitter = clock, noJitter
+			fetch, calls := failing(tc.errs...)
+
+			price, err := tc.policy.Do(ctx, fetch)
+			if price != tc.expected {
+				t.Errorf("expected price %d, got %d", tc.expected, price)
+			}
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != tc.expectedErr {
+				t.Errorf("expected error `%s`, got `%s`", tc.expectedErr, errStr)
+			}
+			if *calls != tc.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tc.expectedCalls, *calls)
+			}
+			if waits := clock.Waits(); !slices.Equal(waits, tc.expectedWaits) {
+				t.Errorf("expected waits %v, got %v", tc.expectedWaits, waits)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_DoCancelledWhileWaiting(t *testing.T) {
+	clock := newFakeClock()
+	clock.block = true
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, Clock: clock, Jitter: noJitter}
+
+	fetch, _ := failing(ErrUnavailable, ErrUnavailable)
+	done := make(chan error)
+	go func() {
+		_, err := policy.Do(ctx, fetch)
+		done <- err
+	}()
+	for len(clock.Waits()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	err := <-done
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRetryPolicy_FullJitter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	seen := map[bool]bool{}
+	for range 1000 {
+		d := p.jitter(p.backoff(1))
+		if d < 0 || d > 100*time.Millisecond {
+			t.Fatalf("jitter %v outside [0, 100ms]", d)
+		}
+		seen[d < 50*time.Millisecond] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected waits on both halves of the range")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{ErrUnavailable, true},
+		{fmt.Errorf("price api: %w", ErrUnavailable), true},
+		{os.ErrDeadlineExceeded, true}, // an i/o timeout
+		{errors.New("remote error"), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, tc := range testCases {
+		if got := IsRetryable(tc.err); got != tc.expected {
+			t.Errorf("IsRetryable(%v): expected %v, got %v", tc.err, tc.expected, got)
+		}
+	}
+}
+
+func TestFetcher_FetchAllWithRetry(t *testing.T) {
+	clock := newFakeClock()
+	retry := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, Clock: clock, Jitter: noJitter}
+
+	f := &Fetcher{Source: PriceSourceFunc(mockFetch), MaxConcurrent: 2, Policy: BestEffort, Retry: retry}
+	result, failed, err := f.FetchAllPartial(context.Background(), []string{"p1", "flaky:x", "bad:y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]int{"p1": priceOf("p1")}; !mapsEqual(expected, result) {
+		t.Errorf("expected map %v, got %v", expected, result)
+	}
+	if err := failed["flaky:x"]; err == nil || err.Error() != "after 4 attempts: upstream unavailable" {
+		t.Errorf("expected flaky:x to fail after 4 attempts, got %v", err)
+	}
+	if err := failed["bad:y"]; err == nil || err.Error() != "remote error" {
+		t.Errorf("expected bad:y to fail once, got %v", err)
+	}
+	if waits := len(clock.Waits()); waits != 3 {
+		t.Errorf("expected 3 waits, got %d", waits)
+	}
+}
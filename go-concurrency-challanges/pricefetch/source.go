@@ -0,0 +1,63 @@
+package pricefetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var ErrUnknownID = errors.New("unknown id")
+
+// HTTPSource asks a price API for GET {BaseURL}/prices/{id}, answered
+// with {"price": 42}. 429 and 5xx answers wrap ErrUnavailable, so the
+// default retry classifier retries them; 404 is ErrUnknownID.
+type HTTPSource struct {
+	BaseURL string
+	Client  *http.Client // http.DefaultClient when nil
+}
+
+func (s HTTPSource) Price(ctx context.Context, id string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.BaseURL, "/")+"/prices/"+url.PathEscape(id), nil)
+	if err != nil {
+		return 0, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	contents, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, ErrUnknownID
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return 0, fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+	default:
+		return 0, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(contents)))
+	}
+
+	var body struct {
+		Price *int `json:"price"`
+	}
+	if err := json.Unmarshal(contents, &body); err != nil {
+		return 0, err
+	}
+	if body.Price == nil {
+		return 0, errors.New("response without a price")
+	}
+	return *body.Price, nil
+}
@@ -0,0 +1,116 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPSource_Price(t *testing.T) {
+	type info struct {
+		code int
+		body string
+	}
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			answers := map[string]info{
+				"/prices/p1":        {http.StatusOK, `{"price": 42}`},
+				"/prices/a%2Fb":     {http.StatusOK, `{"price": 7}`},
+				"/prices/missing":   {http.StatusNotFound, "no such id"},
+				"/prices/busy":      {http.StatusServiceUnavailable, "try later"},
+				"/prices/throttled": {http.StatusTooManyRequests, "slow down"},
+				"/prices/teapot":    {http.StatusTeapot, "short and stout"},
+				"/prices/empty":     {http.StatusOK, `{}`},
+			}
+			a, ok := answers[req.URL.EscapedPath()]
+			if !ok {
+				rw.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			rw.WriteHeader(a.code)
+			rw.Write([]byte(a.body))
+		}))
+	defer server.Close()
+	source := HTTPSource{BaseURL: server.URL + "/", Client: server.Client()}
+
+	data := []struct {
+		name   string
+		id     string
+		result int
+		errMsg string
+	}{
+		{"ok", "p1", 42, ""},
+		{"escaped", "a/b", 7, ""},
+		{"unknown", "missing", 0, "unknown id"},
+		{"unavailable", "busy", 0, "upstream unavailable: 503 Service Unavailable"},
+		{"throttled", "throttled", 0, "upstream unavailable: 429 Too Many Requests"},
+		{"other status", "teapot", 0, "418 I'm a teapot: short and stout"},
+		{"no price", "empty", 0, "response without a price"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			result, err := source.Price(context.Background(), d.id)
+			if result != d.result {
+				t.Errorf("expected `%d`, got `%d`", d.result, result)
+			}
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != d.errMsg {
+				t.Errorf("expected error `%s`, got `%s`", d.errMsg, errMsg)
+			}
+		})
+	}
+}
+
+func TestFetcher_HTTPSource(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = map[string]int{}
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			id := strings.TrimPrefix(req.URL.Path, "/prices/")
+			mu.Lock()
+			calls[id]++
+			n := calls[id]
+			mu.Unlock()
+			switch {
+			case id == "gone":
+				rw.WriteHeader(http.StatusNotFound)
+			case n == 1: // every ID is busy on the first try
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				fmt.Fprintf(rw, `{"price": %d}`, len(id))
+			}
+		}))
+	defer server.Close()
+
+	f := &Fetcher{
+		Source:        HTTPSource{BaseURL: server.URL, Client: server.Client()},
+		MaxConcurrent: 2,
+		Strategy:      WorkerPool{},
+		Policy:        BestEffort,
+		Retry:         RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, Clock: newFakeClock(), Jitter: noJitter},
+	}
+	result, failed, err := f.FetchAllPartial(context.Background(), []string{"a", "bb", "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]int{"a": 1, "bb": 2}; !mapsEqual(expected, result) {
+		t.Errorf("expected map %v, got %v", expected, result)
+	}
+	if !errors.Is(failed["gone"], ErrUnknownID) || len(failed) != 1 {
+		t.Errorf("expected gone to be unknown, got %v", failed)
+	}
+	if calls["gone"] != 1 {
+		t.Errorf("expected no retry of an unknown id, got %d calls", calls["gone"])
+	}
+}
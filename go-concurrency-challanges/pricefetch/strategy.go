@@ -0,0 +1,69 @@
+package pricefetch
+
+import (
+	"context"
+	"sync"
+)
+
+// Strategy calls fetch for every ID with at most limit calls in flight.
+// It starts no new call once ctx is done, and returns when every call it
+// started has returned.
+type Strategy interface {
+	Run(ctx context.Context, ids []string, limit int, fetch func(id string))
+}
+
+// Semaphore starts a goroutine per ID, each waiting for one of limit
+// slots of a buffered channel.
+type Semaphore struct{}
+
+func (Semaphore) Run(ctx context.Context, ids []string, limit int, fetch func(id string)) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, limit)
+	)
+
+outer:
+	for _, id := range ids {
+		select {
+		case <-ctx.Done():
+			break outer
+		default:
+		}
+
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			fetch(id)
+		}(id)
+	}
+	wg.Wait()
+}
+
+// WorkerPool runs the IDs on a Pool of limit workers, handing each ID to
+// the next free worker.
+type WorkerPool struct{}
+
+func (WorkerPool) Run(ctx context.Context, ids []string, limit int, fetch func(id string)) {
+	pool := NewPool(limit, 0, func(_ context.Context, id string) struct{} {
+		if ctx.Err() == nil { // taken as ctx ended
+			fetch(id)
+		}
+		return struct{}{}
+	})
+	for _, id := range ids {
+		if _, err := pool.Submit(ctx, id); err != nil {
+			break // ctx is done
+		}
+	}
+	pool.Shutdown(context.Background())
+}
@@ -0,0 +1,70 @@
+package pricefetch
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// Result is the outcome of fetching one ID.
+type Result struct {
+	Price int
+	Err   error
+}
+
+// --- main logic ---
+
+// Stream yields every ID with its result as soon as it is fetched, in
+// completion order. Results are handed over unbuffered, so a slow
+// consumer holds up the fetches instead of piling results up, and no
+// more than MaxConcurrent are ever in flight or waiting.
+//
+// Stream ignores Policy: failures are yielded like prices, and the
+// consumer stops early by breaking out of the loop. It also stops, without
+// telling, when ctx is done; check ctx.Err() after the loop. Either way it
+// returns only once every fetch it started has returned.
+//
+// An ID listed n times is fetched once and yielded n times in a row.
+func (f *Fetcher) Stream(ctx context.Context, ids []string) iter.Seq2[string, Result] {
+	return func(yield func(string, Result) bool) {
+		if ctx.Err() != nil {
+			return
+		}
+		ids, count := unique(ids)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type item struct {
+			id     string
+			result Result
+		}
+		out := make(chan item)
+		fetch := f.fetchFunc()
+		go func() {
+			defer close(out)
+			f.strategy().Run(ctx, ids, max(f.MaxConcurrent, 1), func(id string) {
+				price, err := fetch(ctx, id)
+				if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+					return // cut short, not a result
+				}
+				select {
+				case out <- item{id, Result{price, err}}:
+				case <-ctx.Done():
+				}
+			})
+		}()
+
+	consume:
+		for it := range out {
+			for range count[it.id] {
+				if !yield(it.id, it.result) {
+					break consume
+				}
+			}
+		}
+		cancel()
+		for range out {
+			// wait for the strategy to return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package pricefetch
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkNoLeaks fails t when goroutines started after it was called are
+// still running once the test is over, goleak style.
+func checkNoLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for runtime.NumGoroutine() > before {
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<20)
+				t.Errorf("%d goroutines leaked:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
+
+func TestFetcher_Stream(t *testing.T) {
+	ids := make([]string, 30)
+	expected := map[string]int{}
+	for i := range ids {
+		ids[i] = fmt.Sprintf("p%d", i)
+		expected[ids[i]] = priceOf(ids[i])
+	}
+	ids = append(ids, "bad:x")
+
+	for _, strategy := range []Strategy{Semaphore{}, WorkerPool{}} {
+		t.Run(fmt.Sprintf("%T", strategy), func(t *testing.T) {
+			checkNoLeaks(t)
+			source := &gaugeSource{}
+			f := &Fetcher{Source: source, MaxConcurrent: 4, Strategy: strategy}
+
+			result := map[string]int{}
+			var failed []string
+			for id, r := range f.Stream(context.Background(), ids) {
+				time.Sleep(time.Millisecond) // a slow consumer
+				if r.Err != nil {
+					failed = append(failed, id)
+					continue
+				}
+				result[id] = r.Price
+			}
+			if !mapsEqual(expected, result) {
+				t.Errorf("expected map %v, got %v", expected, result)
+			}
+			if len(failed) != 1 || failed[0] != "bad:x" {
+				t.Errorf("expected bad:x to fail, got %v", failed)
+			}
+			if peak := source.peak.Load(); peak > 4 {
+				t.Errorf("expected at most 4 fetches in flight, got %d", peak)
+			}
+		})
+	}
+}
+
+func TestFetcher_StreamStopsEarly(t *testing.T) {
+	ids := []string{"p1", "slow:a", "slow:b", "slow:c", "p2", "p3", "slow:d"}
+
+	for _, strategy := range []Strategy{Semaphore{}, WorkerPool{}} {
+		t.Run(fmt.Sprintf("%T break", strategy), func(t *testing.T) {
+			checkNoLeaks(t)
+			// fast IDs are left waiting to hand over their results
+			f := &Fetcher{Source: PriceSourceFunc(mockFetch), MaxConcurrent: 3, Strategy: strategy}
+			n := 0
+			for range f.Stream(context.Background(), []string{"p1", "p2", "p3", "p4", "p5", "slow:a"}) {
+				n++
+				time.Sleep(5 * time.Millisecond)
+				break
+			}
+			if n != 1 {
+				t.Errorf("expected one result, got %d", n)
+			}
+		})
+
+		t.Run(fmt.Sprintf("%T cancel", strategy), func(t *testing.T) {
+			checkNoLeaks(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			f := &Fetcher{Source: PriceSourceFunc(mockFetch), MaxConcurrent: 2, Strategy: strategy}
+			for id, r := range f.Stream(ctx, ids) {
+				if strings.HasPrefix(id, "slow:") || r.Err != nil {
+					t.Errorf("unexpected result for %s: %+v", id, r)
+				}
+			}
+			if ctx.Err() == nil {
+				t.Error("expected the stream to end with the context")
+			}
+		})
+	}
+}
+
+func TestFetcher_StreamDefaults(t *testing.T) {
+	checkNoLeaks(t)
+	result := map[string]int{}
+	f := &Fetcher{Source: PriceSourceFunc(mockFetch), MaxConcurrent: 2, Retry: DefaultRetry}
+	for id, r := range f.Stream(context.Background(), []string{"p1", "p2", "p3"}) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		result[id] = r.Price
+	}
+	if expected := map[string]int{"p1": priceOf("p1"), "p2": priceOf("p2"), "p3": priceOf("p3")}; !mapsEqual(expected, result) {
+		t.Errorf("expected map %v, got %v", expected, result)
+	}
+}